@@ -3,15 +3,62 @@ package main
 import (
 	"docker-deployment/src/service"
 	"docker-deployment/src/utils"
+	"docker-deployment/src/validation"
 	"os"
 )
 
 func main() {
-	dockerComposeFile := os.Getenv("DOCKER_COMPOSE_FILE")
-	timeoutStr := os.Getenv("TIMEOUT")
-	force := utils.GetBoolEnv("FORCE", false)
+	config := service.Config{
+		ComposeFile: os.Getenv("DOCKER_COMPOSE_FILE"),
+		Timeout:     os.Getenv("TIMEOUT"),
+		Force:       utils.GetBoolEnv("FORCE", false),
+		Strategy:    os.Getenv("DEPLOY_STRATEGY"),
+		Project:     os.Getenv("COMPOSE_PROJECT_NAME"),
+		BatchSize:   utils.GetIntEnv("ROLLING_BATCH_SIZE", 1),
+		DryRun:      utils.GetBoolEnv("DRY_RUN", false),
+		Compose:     os.Getenv("COMPOSE_IMPLEMENTATION"),
 
-	utils.EnvLoader(dockerComposeFile)
+		HistoryVolume:   os.Getenv("HISTORY_VOLUME"),
+		StabilityWindow: os.Getenv("STABILITY_WINDOW"),
 
-	service.Start(timeoutStr, dockerComposeFile, force)
+		DiagnosticLogLines: utils.GetIntEnv("DIAGNOSTIC_LOG_LINES", validation.DefaultLogLines),
+		PullConcurrency:    utils.GetIntEnv("PULL_CONCURRENCY", service.DefaultPullConcurrency),
+		PullRetries:        utils.GetIntEnv("PULL_RETRIES", service.DefaultPullRetries),
+
+		RegistryHost:            os.Getenv("DOCKER_REGISTRY_HOST"),
+		RegistryUsername:        os.Getenv("DOCKER_REGISTRY_USERNAME"),
+		RegistryPassword:        os.Getenv("DOCKER_REGISTRY_PASSWORD"),
+		RegistryCredentialsFile: os.Getenv("REGISTRY_CREDENTIALS_FILE"),
+		RegistryDockerConfig:    os.Getenv("REGISTRY_DOCKER_CONFIG"),
+		PolicyFile:              os.Getenv("POLICY_FILE"),
+	}
+
+	command := "deploy"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	switch command {
+	case "deploy":
+		utils.EnvLoader(config.ComposeFile)
+		if err := service.Start(config); err != nil {
+			os.Exit(1)
+		}
+	case "plan":
+		utils.EnvLoader(config.ComposeFile)
+		service.PrintPlan(config, os.Getenv("PLAN_FORMAT"))
+	case "rollback":
+		id := ""
+		if len(os.Args) > 2 {
+			id = os.Args[2]
+		}
+		if err := service.RollbackTo(config, id); err != nil {
+			os.Exit(1)
+		}
+	case "history":
+		service.PrintHistory(config)
+	default:
+		utils.Logger(utils.ColorRed, "Unknown command %q, expected deploy, plan, rollback or history", command)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,134 @@
+package compose
+
+import (
+	"context"
+	"docker-deployment/src/runner"
+	"docker-deployment/src/utils"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Compose implementations.
+const (
+	Auto       = "auto"
+	Plugin     = "plugin"
+	Standalone = "standalone"
+)
+
+// Binary is a compose implementation available on the host.
+type Binary struct {
+	Kind    string
+	Name    string
+	Args    []string
+	Version string
+}
+
+// String describes the binary, e.g. "docker compose 2.24.5".
+func (b *Binary) String() string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s", strings.Join(append([]string{b.Name}, b.Args...), " "), b.Version))
+}
+
+// Command returns the command running compose with args.
+func (b *Binary) Command(args ...string) runner.Command {
+	return runner.Command{Name: b.Name, Args: append(append([]string{}, b.Args...), args...)}
+}
+
+var candidates = map[string]Binary{
+	Plugin:     {Kind: Plugin, Name: "docker", Args: []string{"compose"}},
+	Standalone: {Kind: Standalone, Name: "docker-compose"},
+}
+
+var (
+	current      *Binary
+	currentMutex sync.Mutex
+)
+
+// Detect returns the compose implementation to use. With Auto, or an empty preference, the
+// plugin is preferred over the standalone binary.
+func Detect(ctx context.Context, preferred string) (*Binary, error) {
+	order := []string{Plugin, Standalone}
+	switch preferred {
+	case "", Auto:
+	case Plugin, Standalone:
+		order = []string{preferred}
+	default:
+		return nil, fmt.Errorf("unknown compose implementation %q, expected %s, %s or %s", preferred, Auto, Plugin, Standalone)
+	}
+
+	var failures []string
+	for _, kind := range order {
+		binary := candidates[kind]
+		command := binary.Command("version", "--short")
+		command.ReadOnly = true
+
+		output, err := runner.RunCommand(ctx, command)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", binary.String(), strings.TrimSpace(firstLine(string(output), err.Error()))))
+			continue
+		}
+
+		binary.Version = strings.TrimPrefix(strings.TrimSpace(firstLine(string(output), "")), "v")
+		return &binary, nil
+	}
+
+	return nil, fmt.Errorf("no compose implementation found (%s)", strings.Join(failures, "; "))
+}
+
+// Use makes binary the implementation used by Run.
+func Use(binary *Binary) {
+	currentMutex.Lock()
+	defer currentMutex.Unlock()
+	current = binary
+}
+
+// Current returns the implementation used by Run, detecting it on first use.
+func Current(ctx context.Context) (*Binary, error) {
+	currentMutex.Lock()
+	defer currentMutex.Unlock()
+
+	if current == nil {
+		binary, err := Detect(ctx, Auto)
+		if err != nil {
+			return nil, err
+		}
+		utils.Logger(utils.ColorBlue, "Using %s", binary)
+		current = binary
+	}
+	return current, nil
+}
+
+// Run executes compose with args through the default runner and returns its combined output.
+func Run(ctx context.Context, args ...string) ([]byte, error) {
+	binary, err := Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return runner.RunCommand(ctx, binary.Command(args...))
+}
+
+// ProjectArgs returns args prefixed with the project name and compose file, so compose never derives
+// another project from COMPOSE_PROJECT_NAME, the file or its directory.
+func ProjectArgs(project string, composePath string, args ...string) []string {
+	return append([]string{"-p", project, "-f", composePath}, args...)
+}
+
+// Stream executes compose with args like Run, writing its output to output while it runs.
+func Stream(ctx context.Context, output io.Writer, args ...string) ([]byte, error) {
+	binary, err := Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	command := binary.Command(args...)
+	command.Output = output
+	return runner.RunCommand(ctx, command)
+}
+
+func firstLine(value string, fallback string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fallback
+	}
+	return strings.SplitN(value, "\n", 2)[0]
+}
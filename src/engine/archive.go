@@ -0,0 +1,31 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"net/url"
+)
+
+// CopyToContainer extracts the tar archive into path inside the container. The container does not
+// need to be running.
+func (c *Client) CopyToContainer(ctx context.Context, containerID string, path string, archive io.Reader) error {
+	query := url.Values{"path": {path}}
+	headers := map[string]string{"Content-Type": "application/x-tar"}
+
+	resp, err := c.request(ctx, "PUT", "/containers/"+url.PathEscape(containerID)+"/archive", query, archive, headers)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// CopyFromContainer returns a tar archive of path inside the container. The caller must close it.
+func (c *Client) CopyFromContainer(ctx context.Context, containerID string, path string) (io.ReadCloser, error) {
+	query := url.Values{"path": {path}}
+	resp, err := c.request(ctx, "GET", "/containers/"+url.PathEscape(containerID)+"/archive", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
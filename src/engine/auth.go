@@ -0,0 +1,24 @@
+package engine
+
+import (
+	"context"
+)
+
+// AuthConfig holds the credentials of a registry.
+type AuthConfig struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+}
+
+// Auth validates the credentials against the registry and returns the identity token, if any.
+func (c *Client) Auth(ctx context.Context, auth AuthConfig) (string, error) {
+	var response struct {
+		Status        string `json:"Status"`
+		IdentityToken string `json:"IdentityToken"`
+	}
+	if err := c.call(ctx, "POST", "/auth", nil, auth, &response); err != nil {
+		return "", err
+	}
+	return response.IdentityToken, nil
+}
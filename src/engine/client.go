@@ -0,0 +1,267 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"docker-deployment/src/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// APIVersion is the Docker Engine API version spoken by the client (Docker Engine 20.10+).
+	APIVersion        = "v1.41"
+	defaultUnixSocket = "/var/run/docker.sock"
+)
+
+var (
+	// ErrNotFound is returned when the requested object does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when the object conflicts with an existing one, e.g. a container name in use.
+	ErrConflict = errors.New("conflict")
+)
+
+// Client talks to the Docker Engine HTTP API.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	host       string
+	dryRun     bool
+}
+
+var (
+	defaultClient    *Client
+	defaultClientErr error
+	defaultMutex     sync.Mutex
+)
+
+// Default returns the client used by the other packages, configured from the environment on first use.
+func Default() (*Client, error) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+
+	if defaultClient == nil && defaultClientErr == nil {
+		defaultClient, defaultClientErr = NewFromEnv()
+	}
+	return defaultClient, defaultClientErr
+}
+
+// SetDefault replaces the client returned by Default, e.g. with one talking to a fake daemon.
+func SetDefault(client *Client) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	defaultClient, defaultClientErr = client, nil
+}
+
+// NewFromEnv creates a client honouring DOCKER_HOST, DOCKER_TLS_VERIFY and DOCKER_CERT_PATH.
+func NewFromEnv() (*Client, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = "unix://" + defaultUnixSocket
+	}
+
+	var tlsConfig *tls.Config
+	if verify := os.Getenv("DOCKER_TLS_VERIFY"); verify != "" && verify != "0" {
+		certPath := os.Getenv("DOCKER_CERT_PATH")
+		if certPath == "" {
+			home, _ := os.UserHomeDir()
+			certPath = filepath.Join(home, ".docker")
+		}
+
+		var err error
+		if tlsConfig, err = loadTLSConfig(certPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return New(host, tlsConfig)
+}
+
+// New creates a client for a unix:// or tcp:// host. TLS is used for tcp hosts when tlsConfig is not nil.
+func New(host string, tlsConfig *tls.Config) (*Client, error) {
+	parsed, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+
+	transport := &http.Transport{
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	client := &Client{httpClient: &http.Client{Transport: transport}, host: host}
+
+	switch parsed.Scheme {
+	case "unix":
+		socket := parsed.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		client.baseURL = "http://docker"
+	case "tcp", "http", "https":
+		scheme := "http"
+		if tlsConfig != nil || parsed.Scheme == "https" {
+			scheme = "https"
+			transport.TLSClientConfig = tlsConfig
+		}
+		client.baseURL = fmt.Sprintf("%s://%s", scheme, parsed.Host)
+	default:
+		return nil, fmt.Errorf("unsupported docker host %q", host)
+	}
+
+	return client, nil
+}
+
+// SetDryRun makes the calls that change containers, images or networks only print what they would do.
+func (c *Client) SetDryRun(enabled bool) {
+	c.dryRun = enabled
+}
+
+// skip reports whether a mutating call must be skipped, printing it when it is.
+func (c *Client) skip(action string, args ...any) bool {
+	if c.dryRun {
+		utils.Logger(utils.ColorYellow, "[dry-run] "+action, args...)
+	}
+	return c.dryRun
+}
+
+// Host returns the docker host the client is connected to.
+func (c *Client) Host() string {
+	return c.host
+}
+
+// Hostname returns the address of the docker host, localhost when the daemon is reached through a socket.
+func (c *Client) Hostname() string {
+	parsed, err := url.Parse(c.host)
+	if err != nil || parsed.Scheme == "unix" || parsed.Hostname() == "" {
+		return "localhost"
+	}
+	return parsed.Hostname()
+}
+
+func loadTLSConfig(certPath string) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(filepath.Join(certPath, "cert.pem"), filepath.Join(certPath, "key.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("error loading docker client certificate: %w", err)
+	}
+
+	caCert, err := os.ReadFile(filepath.Join(certPath, "ca.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("error loading docker CA certificate: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("invalid docker CA certificate in %s", certPath)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// request sends a request to the API and returns the response when its status is successful.
+// The caller must close the response body.
+func (c *Client) request(ctx context.Context, method string, path string, query url.Values, body any, headers map[string]string) (*http.Response, error) {
+	// Readers are sent as they are, anything else is encoded as JSON
+	var reader io.Reader
+	isJSON := false
+	switch value := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = value
+	default:
+		content, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(content)
+		isJSON = true
+	}
+
+	endpoint := fmt.Sprintf("%s/%s%s", c.baseURL, APIVersion, path)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, err
+	}
+	if isJSON {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling docker engine: %w", err)
+	}
+
+	// 304 is returned when starting or stopping a container that is already in that state
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	message := readErrorMessage(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, message)
+	case http.StatusConflict:
+		return nil, fmt.Errorf("%w: %s", ErrConflict, message)
+	}
+	return nil, fmt.Errorf("docker engine %s %s: %s (%d)", method, path, message, resp.StatusCode)
+}
+
+// call sends a request and decodes the JSON response into out when out is not nil.
+func (c *Client) call(ctx context.Context, method string, path string, query url.Values, body any, out any) error {
+	resp, err := c.request(ctx, method, path, query, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil || resp.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func readErrorMessage(body io.Reader) string {
+	content, _ := io.ReadAll(io.LimitReader(body, 64*1024))
+
+	var apiError struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(content, &apiError) == nil && apiError.Message != "" {
+		return apiError.Message
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// filtersQuery encodes filters the way the Engine API expects them.
+func filtersQuery(filters map[string][]string) string {
+	if len(filters) == 0 {
+		return ""
+	}
+	encoded, _ := json.Marshal(filters)
+	return string(encoded)
+}
@@ -0,0 +1,17 @@
+package engine
+
+// Labels set by docker compose on the containers it creates.
+const (
+	ComposeProjectLabel     = "com.docker.compose.project"
+	ComposeServiceLabel     = "com.docker.compose.service"
+	ComposeConfigFilesLabel = "com.docker.compose.project.config_files"
+)
+
+// ProjectFilter selects the containers of a compose project, optionally limited to some services.
+func ProjectFilter(project string, services ...string) map[string][]string {
+	filters := map[string][]string{"label": {ComposeProjectLabel + "=" + project}}
+	for _, service := range services {
+		filters["label"] = append(filters["label"], ComposeServiceLabel+"="+service)
+	}
+	return filters
+}
@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Container is a container as returned by the list endpoint.
+type Container struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	ImageID string            `json:"ImageID"`
+	Labels  map[string]string `json:"Labels"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+	// NetworkSettings lists the networks the container is attached to.
+	NetworkSettings struct {
+		Networks map[string]struct {
+			NetworkID string `json:"NetworkID"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// Name returns the container name without the leading slash.
+func (c Container) Name() string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// HealthLog is the result of a single healthcheck probe.
+type HealthLog struct {
+	Start    time.Time `json:"Start"`
+	End      time.Time `json:"End"`
+	ExitCode int       `json:"ExitCode"`
+	Output   string    `json:"Output"`
+}
+
+// Health is the healthcheck state of a container.
+type Health struct {
+	Status        string      `json:"Status"`
+	FailingStreak int         `json:"FailingStreak"`
+	Log           []HealthLog `json:"Log"`
+}
+
+// ContainerState is the runtime state of a container.
+type ContainerState struct {
+	Status     string    `json:"Status"`
+	Running    bool      `json:"Running"`
+	Paused     bool      `json:"Paused"`
+	Restarting bool      `json:"Restarting"`
+	OOMKilled  bool      `json:"OOMKilled"`
+	Dead       bool      `json:"Dead"`
+	Pid        int       `json:"Pid"`
+	ExitCode   int       `json:"ExitCode"`
+	Error      string    `json:"Error"`
+	StartedAt  time.Time `json:"StartedAt"`
+	FinishedAt time.Time `json:"FinishedAt"`
+	Health     *Health   `json:"Health"`
+}
+
+// HealthConfig is the healthcheck configured on a container.
+type HealthConfig struct {
+	Test        []string      `json:"Test"`
+	Interval    time.Duration `json:"Interval"`
+	Timeout     time.Duration `json:"Timeout"`
+	StartPeriod time.Duration `json:"StartPeriod"`
+	Retries     int           `json:"Retries"`
+}
+
+// ContainerConfig is the configuration a container was created with.
+type ContainerConfig struct {
+	Image       string            `json:"Image"`
+	Cmd         []string          `json:"Cmd"`
+	Entrypoint  []string          `json:"Entrypoint"`
+	Env         []string          `json:"Env"`
+	User        string            `json:"User"`
+	WorkingDir  string            `json:"WorkingDir"`
+	Tty         bool              `json:"Tty"`
+	Labels      map[string]string `json:"Labels"`
+	Healthcheck *HealthConfig     `json:"Healthcheck"`
+}
+
+// RestartPolicy is the restart policy of a container.
+type RestartPolicy struct {
+	Name              string `json:"Name"`
+	MaximumRetryCount int    `json:"MaximumRetryCount"`
+}
+
+// HostConfig is the host configuration of a container.
+type HostConfig struct {
+	RestartPolicy RestartPolicy `json:"RestartPolicy"`
+	NetworkMode   string        `json:"NetworkMode"`
+}
+
+// PortBinding is a container port published on the host.
+type PortBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+// NetworkSettings are the ports and networks of a running container.
+type NetworkSettings struct {
+	// Ports maps a container port such as "8080/tcp" to its host bindings
+	Ports    map[string][]PortBinding `json:"Ports"`
+	Networks map[string]struct {
+		IPAddress string `json:"IPAddress"`
+	} `json:"Networks"`
+}
+
+// ContainerJSON is a container as returned by the inspect endpoint.
+type ContainerJSON struct {
+	ID           string          `json:"Id"`
+	Name         string          `json:"Name"`
+	Image        string          `json:"Image"`
+	Created      time.Time       `json:"Created"`
+	RestartCount int             `json:"RestartCount"`
+	State        ContainerState  `json:"State"`
+	Config       ContainerConfig `json:"Config"`
+	HostConfig   HostConfig      `json:"HostConfig"`
+	// NetworkSettings is only filled for running containers
+	NetworkSettings NetworkSettings `json:"NetworkSettings"`
+	Raw             json.RawMessage `json:"-"`
+}
+
+// ShortName returns the container name without the leading slash.
+func (c *ContainerJSON) ShortName() string {
+	return strings.TrimPrefix(c.Name, "/")
+}
+
+// ContainerList lists containers matching filters, including stopped ones when all is set.
+func (c *Client) ContainerList(ctx context.Context, all bool, filters map[string][]string) ([]Container, error) {
+	query := url.Values{}
+	if all {
+		query.Set("all", "1")
+	}
+	if encoded := filtersQuery(filters); encoded != "" {
+		query.Set("filters", encoded)
+	}
+
+	var containers []Container
+	if err := c.call(ctx, "GET", "/containers/json", query, nil, &containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
+
+// ContainerInspect returns the low level information of a container. It returns ErrNotFound
+// when the container does not exist.
+func (c *Client) ContainerInspect(ctx context.Context, nameOrID string) (*ContainerJSON, error) {
+	resp, err := c.request(ctx, "GET", "/containers/"+url.PathEscape(nameOrID)+"/json", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var container ContainerJSON
+	if err := json.Unmarshal(raw, &container); err != nil {
+		return nil, fmt.Errorf("error decoding container %s: %w", nameOrID, err)
+	}
+	container.Raw = raw
+
+	return &container, nil
+}
+
+// ContainerStart starts a container.
+func (c *Client) ContainerStart(ctx context.Context, nameOrID string) error {
+	if c.skip("start container %s", nameOrID) {
+		return nil
+	}
+	return c.call(ctx, "POST", "/containers/"+url.PathEscape(nameOrID)+"/start", nil, nil, nil)
+}
+
+// ContainerStop stops a container, killing it after the daemon default grace period.
+func (c *Client) ContainerStop(ctx context.Context, nameOrID string) error {
+	if c.skip("stop container %s", nameOrID) {
+		return nil
+	}
+	return c.call(ctx, "POST", "/containers/"+url.PathEscape(nameOrID)+"/stop", nil, nil, nil)
+}
+
+// ContainerRename renames a container.
+func (c *Client) ContainerRename(ctx context.Context, nameOrID string, newName string) error {
+	if c.skip("rename container %s to %s", nameOrID, newName) {
+		return nil
+	}
+	query := url.Values{"name": {newName}}
+	return c.call(ctx, "POST", "/containers/"+url.PathEscape(nameOrID)+"/rename", query, nil, nil)
+}
+
+// ContainerRemove removes a container, killing it first when force is set.
+func (c *Client) ContainerRemove(ctx context.Context, nameOrID string, force bool) error {
+	if c.skip("remove container %s", nameOrID) {
+		return nil
+	}
+	query := url.Values{"force": {strconv.FormatBool(force)}}
+	return c.call(ctx, "DELETE", "/containers/"+url.PathEscape(nameOrID), query, nil, nil)
+}
+
+// Mount is a volume or bind mount of a container created with ContainerCreate.
+type Mount struct {
+	Type     string `json:"Type"`
+	Source   string `json:"Source"`
+	Target   string `json:"Target"`
+	ReadOnly bool   `json:"ReadOnly,omitempty"`
+}
+
+// CreateOptions describes a container created with ContainerCreate.
+type CreateOptions struct {
+	Name   string
+	Image  string
+	Cmd    []string
+	Env    []string
+	Labels map[string]string
+	Mounts []Mount
+	// NetworkMode is the network the container is attached to, the default bridge when empty.
+	NetworkMode string
+}
+
+// ContainerCreate creates a container without starting it and returns its id.
+func (c *Client) ContainerCreate(ctx context.Context, options CreateOptions) (string, error) {
+	query := url.Values{}
+	if options.Name != "" {
+		query.Set("name", options.Name)
+	}
+
+	hostConfig := map[string]any{"Mounts": options.Mounts}
+	if options.NetworkMode != "" {
+		hostConfig["NetworkMode"] = options.NetworkMode
+	}
+	request := map[string]any{
+		"Image":      options.Image,
+		"Cmd":        options.Cmd,
+		"Env":        options.Env,
+		"Labels":     options.Labels,
+		"HostConfig": hostConfig,
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := c.call(ctx, "POST", "/containers/create", query, request, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// ContainerWait waits for a container to stop and returns its exit code.
+func (c *Client) ContainerWait(ctx context.Context, nameOrID string) (int, error) {
+	var response struct {
+		StatusCode int `json:"StatusCode"`
+		Error      *struct {
+			Message string `json:"Message"`
+		} `json:"Error"`
+	}
+	if err := c.call(ctx, "POST", "/containers/"+url.PathEscape(nameOrID)+"/wait", nil, nil, &response); err != nil {
+		return 0, err
+	}
+	if response.Error != nil && response.Error.Message != "" {
+		return response.StatusCode, fmt.Errorf("error waiting for container %s: %s", nameOrID, response.Error.Message)
+	}
+	return response.StatusCode, nil
+}
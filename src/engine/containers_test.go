@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// newTestClient returns a client talking to handler, which receives the paths without the API version.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.StripPrefix("/"+APIVersion, handler))
+	t.Cleanup(server.Close)
+
+	client, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestContainerListFilters(t *testing.T) {
+	var query map[string][]string
+	var all string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		all = r.URL.Query().Get("all")
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("filters")), &query); err != nil {
+			t.Errorf("invalid filters %q: %s", r.URL.Query().Get("filters"), err)
+		}
+		_, _ = w.Write([]byte(`[{"Id":"0123456789abcdef","Names":["/shop-web-1"],"Labels":{"com.docker.compose.service":"web"}}]`))
+	})
+
+	containers, err := client.ContainerList(context.Background(), true, ProjectFilter("shop", "web", "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{"label": {
+		"com.docker.compose.project=shop",
+		"com.docker.compose.service=web",
+		"com.docker.compose.service=db",
+	}}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("filters = %v, want %v", query, want)
+	}
+	if all != "1" {
+		t.Errorf("all = %q, want 1", all)
+	}
+	if len(containers) != 1 || containers[0].Name() != "shop-web-1" || containers[0].Labels[ComposeServiceLabel] != "web" {
+		t.Errorf("unexpected containers %+v", containers)
+	}
+}
+
+func TestContainerInspect(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/web/json":
+			_, _ = w.Write([]byte(`{"Id":"0123456789abcdef","Name":"/web","State":{"Status":"running","Running":true,
+				"Health":{"Status":"healthy"}},"Config":{"Image":"nginx:1.27","Labels":{"com.docker.compose.project":"shop"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"No such container: missing"}`))
+		}
+	})
+
+	container, err := client.ContainerInspect(context.Background(), "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if container.ShortName() != "web" || !container.State.Running || container.State.Health.Status != "healthy" ||
+		container.Config.Labels[ComposeProjectLabel] != "shop" {
+		t.Errorf("unexpected container %+v", container)
+	}
+	if len(container.Raw) == 0 {
+		t.Error("raw inspect output not kept")
+	}
+
+	if _, err := client.ContainerInspect(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// EventActor is the object an event refers to.
+type EventActor struct {
+	ID         string            `json:"ID"`
+	Attributes map[string]string `json:"Attributes"`
+}
+
+// Event is a single message of the events stream.
+type Event struct {
+	Type     string     `json:"Type"`
+	Action   string     `json:"Action"`
+	Actor    EventActor `json:"Actor"`
+	Time     int64      `json:"time"`
+	TimeNano int64      `json:"timeNano"`
+}
+
+// Events streams daemon events matching filters, starting at since when it is not zero.
+// The events channel is closed when the stream ends; the error channel then receives
+// the reason, or nil when ctx was cancelled.
+func (c *Client) Events(ctx context.Context, since time.Time, filters map[string][]string) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+
+		query := url.Values{}
+		if !since.IsZero() {
+			query.Set("since", strconv.FormatInt(since.Unix(), 10))
+		}
+		if encoded := filtersQuery(filters); encoded != "" {
+			query.Set("filters", encoded)
+		}
+
+		resp, err := c.request(ctx, "GET", "/events", query, nil, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = nil
+			}
+			errs <- err
+			return
+		}
+		defer resp.Body.Close()
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var event Event
+			if err := decoder.Decode(&event); err != nil {
+				if ctx.Err() != nil {
+					errs <- nil
+				} else {
+					errs <- err
+				}
+				return
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				errs <- nil
+				return
+			}
+		}
+	}()
+
+	return events, errs
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	var query map[string][]string
+	var since string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		since = r.URL.Query().Get("since")
+		_ = json.Unmarshal([]byte(r.URL.Query().Get("filters")), &query)
+		_, _ = w.Write([]byte(`{"Type":"container","Action":"start","Actor":{"ID":"abc"},"time":1700000000}
+{"Type":"container","Action":"health_status: healthy","Actor":{"ID":"abc","Attributes":{"name":"web"}},"time":1700000001}
+`))
+	})
+
+	filters := map[string][]string{"type": {"container"}, "container": {"abc"}}
+	events, errs := client.Events(context.Background(), time.Unix(1700000000, 0), filters)
+
+	var actions []string
+	for event := range events {
+		actions = append(actions, event.Action)
+	}
+
+	if want := []string{"start", "health_status: healthy"}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("actions = %v, want %v", actions, want)
+	}
+	if since != "1700000000" {
+		t.Errorf("since = %q", since)
+	}
+	if !reflect.DeepEqual(query, filters) {
+		t.Errorf("filters = %v, want %v", query, filters)
+	}
+	// The stream ended without the context being cancelled
+	if err := <-errs; err == nil {
+		t.Error("expected the end of the stream to be reported")
+	}
+}
+
+func TestEventsCancelled(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs := client.Events(ctx, time.Time{}, nil)
+	cancel()
+
+	for range events {
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("error = %v, want nil after cancel", err)
+	}
+}
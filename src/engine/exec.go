@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+)
+
+// ExecResult is the outcome of a command run in a container.
+type ExecResult struct {
+	ExitCode int
+	// Output is the combined stdout and stderr of the command
+	Output string
+}
+
+// ContainerExec runs cmd in a running container, waits for it to finish and returns its output.
+func (c *Client) ContainerExec(ctx context.Context, nameOrID string, cmd []string) (*ExecResult, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	request := map[string]any{"AttachStdout": true, "AttachStderr": true, "Cmd": cmd}
+	if err := c.call(ctx, "POST", "/containers/"+url.PathEscape(nameOrID)+"/exec", nil, request, &created); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.request(ctx, "POST", "/exec/"+created.ID+"/start", nil, map[string]any{"Detach": false, "Tty": false}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// The stream ends when the command exits
+	var output bytes.Buffer
+	if err := demultiplex(resp.Body, &output); err != nil {
+		return nil, err
+	}
+
+	var inspect struct {
+		ExitCode int  `json:"ExitCode"`
+		Running  bool `json:"Running"`
+	}
+	if err := c.call(ctx, "GET", "/exec/"+created.ID+"/json", nil, nil, &inspect); err != nil {
+		return nil, err
+	}
+
+	return &ExecResult{ExitCode: inspect.ExitCode, Output: output.String()}, nil
+}
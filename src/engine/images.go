@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+)
+
+// Image is an image as returned by the inspect endpoint.
+type Image struct {
+	ID          string   `json:"Id"`
+	RepoTags    []string `json:"RepoTags"`
+	RepoDigests []string `json:"RepoDigests"`
+}
+
+// ImageInspect returns the low level information of an image. It returns ErrNotFound when
+// the image does not exist.
+func (c *Client) ImageInspect(ctx context.Context, nameOrID string) (*Image, error) {
+	var image Image
+	if err := c.call(ctx, "GET", "/images/"+escapeImage(nameOrID)+"/json", nil, nil, &image); err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// ImageSummary is an image as returned by the list endpoint.
+type ImageSummary struct {
+	ID          string   `json:"Id"`
+	RepoTags    []string `json:"RepoTags"`
+	RepoDigests []string `json:"RepoDigests"`
+	Size        int64    `json:"Size"`
+}
+
+// ImageList lists the images matching filters.
+func (c *Client) ImageList(ctx context.Context, filters map[string][]string) ([]ImageSummary, error) {
+	query := url.Values{}
+	if encoded := filtersQuery(filters); encoded != "" {
+		query.Set("filters", encoded)
+	}
+
+	var images []ImageSummary
+	if err := c.call(ctx, "GET", "/images/json", query, nil, &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// DistributionInspect returns the manifest digest the registry currently serves for an image reference.
+func (c *Client) DistributionInspect(ctx context.Context, reference string) (string, error) {
+	var distribution struct {
+		Descriptor struct {
+			Digest string `json:"digest"`
+		} `json:"Descriptor"`
+	}
+	if err := c.call(ctx, "GET", "/distribution/"+escapeImage(reference)+"/json", nil, nil, &distribution); err != nil {
+		return "", err
+	}
+	return distribution.Descriptor.Digest, nil
+}
+
+// ImageImport creates repository:tag from the root filesystem in the tar archive.
+func (c *Client) ImageImport(ctx context.Context, archive io.Reader, repository string, tag string) error {
+	query := url.Values{"fromSrc": {"-"}, "repo": {repository}, "tag": {tag}}
+	headers := map[string]string{"Content-Type": "application/x-tar"}
+
+	resp, err := c.request(ctx, "POST", "/images/create", query, archive, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return readProgress(resp.Body)
+}
+
+// ImagePull pulls an image. auth holds the registry credentials, it may be nil for public images.
+func (c *Client) ImagePull(ctx context.Context, reference string, auth *AuthConfig) error {
+	if c.skip("pull image %s", reference) {
+		return nil
+	}
+
+	query := url.Values{"fromImage": {reference}}
+	headers := map[string]string{}
+	if auth != nil {
+		encoded, err := json.Marshal(auth)
+		if err != nil {
+			return err
+		}
+		headers["X-Registry-Auth"] = base64.URLEncoding.EncodeToString(encoded)
+	}
+
+	resp, err := c.request(ctx, "POST", "/images/create", query, nil, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return readProgress(resp.Body)
+}
+
+// readProgress consumes a JSON progress stream, returning the first error it reports.
+func readProgress(stream io.Reader) error {
+	decoder := json.NewDecoder(stream)
+	for {
+		var message struct {
+			Error       string `json:"error"`
+			ErrorDetail struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+	}
+}
+
+// escapeImage keeps the slashes of an image reference, which the API expects unescaped.
+func escapeImage(reference string) string {
+	return (&url.URL{Path: reference}).EscapedPath()
+}
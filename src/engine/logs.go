@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+// LogsOptions selects the logs returned by ContainerLogs.
+type LogsOptions struct {
+	Follow bool
+	// Tail is the number of lines from the end of the logs, all lines when zero.
+	Tail int
+}
+
+// ContainerLogs returns the combined stdout and stderr of a container as plain text.
+func (c *Client) ContainerLogs(ctx context.Context, nameOrID string, options LogsOptions) (io.ReadCloser, error) {
+	container, err := c.ContainerInspect(ctx, nameOrID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if options.Follow {
+		query.Set("follow", "1")
+	}
+	if options.Tail > 0 {
+		query.Set("tail", strconv.Itoa(options.Tail))
+	}
+
+	resp, err := c.request(ctx, "GET", "/containers/"+url.PathEscape(nameOrID)+"/logs", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Containers with a TTY stream raw output, the others multiplex stdout and stderr
+	if container.Config.Tty {
+		return resp.Body, nil
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		defer resp.Body.Close()
+		writer.CloseWithError(demultiplex(resp.Body, writer))
+	}()
+
+	return reader, nil
+}
+
+// ContainerLogLines returns the last lines of the container logs.
+func (c *Client) ContainerLogLines(ctx context.Context, nameOrID string, tail int) ([]string, error) {
+	logs, err := c.ContainerLogs(ctx, nameOrID, LogsOptions{Tail: tail})
+	if err != nil {
+		return nil, err
+	}
+	defer logs.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(logs)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// demultiplex copies the payload of a multiplexed log stream, dropping the frame headers.
+func demultiplex(source io.Reader, destination io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(source, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(destination, source, size); err != nil {
+			return err
+		}
+	}
+}
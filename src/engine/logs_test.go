@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// frame returns a frame of a multiplexed log stream.
+func frame(stream byte, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+func TestContainerLogLines(t *testing.T) {
+	tests := []struct {
+		name string
+		tty  bool
+		body []byte
+		want []string
+	}{
+		{
+			name: "multiplexed",
+			body: bytes.Join([][]byte{frame(1, "listening on :80\n"), frame(2, "warning: no config\n"), frame(1, "ready\n")}, nil),
+			want: []string{"listening on :80", "warning: no config", "ready"},
+		},
+		{
+			name: "frame split across lines",
+			body: bytes.Join([][]byte{frame(1, "first "), frame(1, "line\nsecond\n")}, nil),
+			want: []string{"first line", "second"},
+		},
+		{
+			name: "tty",
+			tty:  true,
+			body: []byte("raw output\nsecond line\n"),
+			want: []string{"raw output", "second line"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var tail string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/containers/web/json":
+					if test.tty {
+						_, _ = w.Write([]byte(`{"Id":"abc","Config":{"Tty":true}}`))
+					} else {
+						_, _ = w.Write([]byte(`{"Id":"abc","Config":{"Tty":false}}`))
+					}
+				case "/containers/web/logs":
+					tail = r.URL.Query().Get("tail")
+					_, _ = w.Write(test.body)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			})
+
+			lines, err := client.ContainerLogLines(context.Background(), "web", 30)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(lines, test.want) {
+				t.Errorf("lines = %q, want %q", lines, test.want)
+			}
+			if tail != "30" {
+				t.Errorf("tail = %q, want 30", tail)
+			}
+		})
+	}
+}
+
+func TestDemultiplexTruncatedFrame(t *testing.T) {
+	var output bytes.Buffer
+	stream := frame(1, "complete\n")
+	stream = append(stream, frame(1, "truncated")[:12]...)
+
+	if err := demultiplex(bytes.NewReader(stream), &output); err == nil {
+		t.Error("expected an error for a truncated frame")
+	}
+	if !strings.HasPrefix(output.String(), "complete\n") {
+		t.Errorf("output = %q", output.String())
+	}
+}
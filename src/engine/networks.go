@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"context"
+	"net/url"
+)
+
+// Network is a network as returned by the list endpoint.
+type Network struct {
+	ID     string            `json:"Id"`
+	Name   string            `json:"Name"`
+	Driver string            `json:"Driver"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// Predefined reports whether the network is one of the networks the daemon creates itself.
+func (n Network) Predefined() bool {
+	switch n.Name {
+	case "bridge", "host", "none":
+		return true
+	}
+	return n.Driver == "overlay" && n.Name == "ingress"
+}
+
+// NetworkList lists the networks matching filters.
+func (c *Client) NetworkList(ctx context.Context, filters map[string][]string) ([]Network, error) {
+	query := url.Values{}
+	if encoded := filtersQuery(filters); encoded != "" {
+		query.Set("filters", encoded)
+	}
+
+	var networks []Network
+	if err := c.call(ctx, "GET", "/networks", query, nil, &networks); err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
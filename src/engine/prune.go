@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"context"
+)
+
+// PruneReport is the outcome of a system prune.
+type PruneReport struct {
+	ContainersDeleted []string
+	NetworksDeleted   []string
+	ImagesDeleted     []string
+	BuildCacheDeleted []string
+	SpaceReclaimed    uint64
+}
+
+// SystemPrune removes stopped containers, unused networks, dangling images and build cache,
+// the same set of objects as `docker system prune -f`.
+func (c *Client) SystemPrune(ctx context.Context, filters map[string][]string) (*PruneReport, error) {
+	report := &PruneReport{}
+	if c.skip("prune stopped containers, unused networks, dangling images and build cache") {
+		return report, nil
+	}
+	query := map[string][]string{}
+	if encoded := filtersQuery(filters); encoded != "" {
+		query["filters"] = []string{encoded}
+	}
+
+	var containers struct {
+		ContainersDeleted []string `json:"ContainersDeleted"`
+		SpaceReclaimed    uint64   `json:"SpaceReclaimed"`
+	}
+	if err := c.call(ctx, "POST", "/containers/prune", query, nil, &containers); err != nil {
+		return nil, err
+	}
+	report.ContainersDeleted = containers.ContainersDeleted
+	report.SpaceReclaimed += containers.SpaceReclaimed
+
+	var networks struct {
+		NetworksDeleted []string `json:"NetworksDeleted"`
+	}
+	if err := c.call(ctx, "POST", "/networks/prune", query, nil, &networks); err != nil {
+		return nil, err
+	}
+	report.NetworksDeleted = networks.NetworksDeleted
+
+	var images struct {
+		ImagesDeleted []struct {
+			Untagged string `json:"Untagged"`
+			Deleted  string `json:"Deleted"`
+		} `json:"ImagesDeleted"`
+		SpaceReclaimed uint64 `json:"SpaceReclaimed"`
+	}
+	if err := c.call(ctx, "POST", "/images/prune", query, nil, &images); err != nil {
+		return nil, err
+	}
+	for _, image := range images.ImagesDeleted {
+		if image.Deleted != "" {
+			report.ImagesDeleted = append(report.ImagesDeleted, image.Deleted)
+		}
+	}
+	report.SpaceReclaimed += images.SpaceReclaimed
+
+	var buildCache struct {
+		CachesDeleted  []string `json:"CachesDeleted"`
+		SpaceReclaimed uint64   `json:"SpaceReclaimed"`
+	}
+	if err := c.call(ctx, "POST", "/build/prune", nil, nil, &buildCache); err != nil {
+		return nil, err
+	}
+	report.BuildCacheDeleted = buildCache.CachesDeleted
+	report.SpaceReclaimed += buildCache.SpaceReclaimed
+
+	return report, nil
+}
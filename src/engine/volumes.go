@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"context"
+	"net/url"
+)
+
+// Volume is a named volume.
+type Volume struct {
+	Name       string            `json:"Name"`
+	Driver     string            `json:"Driver"`
+	Mountpoint string            `json:"Mountpoint"`
+	Labels     map[string]string `json:"Labels"`
+}
+
+// VolumeInspect returns a volume. It returns ErrNotFound when the volume does not exist.
+func (c *Client) VolumeInspect(ctx context.Context, name string) (*Volume, error) {
+	var volume Volume
+	if err := c.call(ctx, "GET", "/volumes/"+url.PathEscape(name), nil, nil, &volume); err != nil {
+		return nil, err
+	}
+	return &volume, nil
+}
+
+// VolumeCreate creates a named volume with labels, returning the existing one when it already exists.
+func (c *Client) VolumeCreate(ctx context.Context, name string, labels map[string]string) (*Volume, error) {
+	request := map[string]any{"Name": name, "Labels": labels}
+
+	var volume Volume
+	if err := c.call(ctx, "POST", "/volumes/create", nil, request, &volume); err != nil {
+		return nil, err
+	}
+	return &volume, nil
+}
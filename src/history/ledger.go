@@ -0,0 +1,314 @@
+package history
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"docker-deployment/src/engine"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"path"
+	"sort"
+	"time"
+)
+
+// Deployment outcomes.
+const (
+	OutcomeSuccess    = "success"
+	OutcomeFailed     = "failed"
+	OutcomeRolledBack = "rolled-back"
+)
+
+const (
+	// DefaultVolume is the volume holding the ledger on the Docker host.
+	DefaultVolume = "docker-deployment-history"
+	// Label marks the ledger volume and the accessor containers, which prunes must leave alone
+	Label       = "docker-deployment.history"
+	mountPath   = "/ledger"
+	historyFile = "history.json"
+	composeDir  = "compose"
+	// The accessor container is created from an empty image and never started
+	accessorImage = "docker-deployment/ledger"
+	accessorTag   = "empty"
+	// lockTimeout bounds the wait for the exclusive accessor held by another runner.
+	lockTimeout = 2 * time.Minute
+	// lockRetryInterval is the pause between two attempts to take the exclusive accessor.
+	lockRetryInterval = 500 * time.Millisecond
+	// staleLockAge is the age after which an exclusive accessor is considered left behind by a runner
+	// that died while recording.
+	staleLockAge = 5 * time.Minute
+)
+
+// Entry is a deployment recorded in the ledger.
+type Entry struct {
+	ID          string            `json:"id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	ComposeFile string            `json:"compose_file"`
+	ComposeHash string            `json:"compose_hash"`
+	Strategy    string            `json:"strategy,omitempty"`
+	Images      map[string]string `json:"images,omitempty"`
+	Outcome     string            `json:"outcome"`
+	Duration    float64           `json:"duration_seconds"`
+	RollbackOf  string            `json:"rollback_of,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Migration   *Migration        `json:"migration,omitempty"`
+}
+
+// Migration is the migration run before the containers of a deployment were started.
+type Migration struct {
+	Service  string   `json:"service"`
+	Command  []string `json:"command,omitempty"`
+	ExitCode int      `json:"exit_code"`
+	Duration float64  `json:"duration_seconds"`
+}
+
+// Ledger is the deployment history stored in a labelled volume on the Docker host, so every
+// runner deploying to the host sees the same history.
+type Ledger struct {
+	client *engine.Client
+	volume string
+}
+
+// Open returns the ledger kept in volume, creating the volume when needed.
+func Open(ctx context.Context, client *engine.Client, volume string) (*Ledger, error) {
+	if volume == "" {
+		volume = DefaultVolume
+	}
+
+	if _, err := client.VolumeInspect(ctx, volume); errors.Is(err, engine.ErrNotFound) {
+		if _, err := client.VolumeCreate(ctx, volume, map[string]string{Label: "true"}); err != nil {
+			return nil, fmt.Errorf("error creating history volume %s: %w", volume, err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("error inspecting history volume %s: %w", volume, err)
+	}
+
+	return &Ledger{client: client, volume: volume}, nil
+}
+
+// HashCompose returns the sha256 of the compose file content.
+func HashCompose(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Entries returns the recorded deployments, oldest first.
+func (l *Ledger) Entries(ctx context.Context) ([]Entry, error) {
+	var entries []Entry
+	err := l.withAccessor(ctx, false, func(containerID string) error {
+		content, err := l.readFile(ctx, containerID, path.Join(mountPath, historyFile))
+		if err != nil || content == nil {
+			return err
+		}
+		return json.Unmarshal(content, &entries)
+	})
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	return entries, err
+}
+
+// Find returns the entry with id.
+func (l *Ledger) Find(ctx context.Context, id string) (*Entry, error) {
+	entries, err := l.Entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].ID == id {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("deployment %s not found in the history", id)
+}
+
+// Compose returns the compose file deployed by the entry with id.
+func (l *Ledger) Compose(ctx context.Context, id string) ([]byte, error) {
+	var content []byte
+	err := l.withAccessor(ctx, false, func(containerID string) error {
+		var err error
+		content, err = l.readFile(ctx, containerID, path.Join(mountPath, composeDir, id+".yaml"))
+		return err
+	})
+	if err == nil && content == nil {
+		err = fmt.Errorf("compose file of deployment %s not found in the history", id)
+	}
+	return content, err
+}
+
+// Record appends the entry to the ledger together with the compose file it deployed. Runners recording
+// at the same time take turns, so no entry is lost.
+func (l *Ledger) Record(ctx context.Context, entry Entry, compose []byte) error {
+	return l.withAccessor(ctx, true, func(containerID string) error {
+		var entries []Entry
+		content, err := l.readFile(ctx, containerID, path.Join(mountPath, historyFile))
+		if err != nil {
+			return err
+		}
+		if content != nil {
+			if err := json.Unmarshal(content, &entries); err != nil {
+				return fmt.Errorf("error decoding history: %w", err)
+			}
+		}
+
+		entries = append(entries, entry)
+		history, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		files := map[string][]byte{historyFile: history}
+		if compose != nil {
+			files[path.Join(composeDir, entry.ID+".yaml")] = compose
+		}
+		return l.writeFiles(ctx, containerID, files)
+	})
+}
+
+// withAccessor runs fn with a stopped container that mounts the ledger volume. An exclusive accessor has
+// a name fixed by the volume, which the Docker Engine gives to a single container at a time: it serves as
+// the lock of the ledger, waited for while another runner holds it.
+func (l *Ledger) withAccessor(ctx context.Context, exclusive bool, fn func(containerID string) error) error {
+	if err := l.ensureAccessorImage(ctx); err != nil {
+		return err
+	}
+
+	options := engine.CreateOptions{
+		Name:   "docker-deployment-ledger-" + uuid.New().String()[:8],
+		Image:  accessorImage + ":" + accessorTag,
+		Cmd:    []string{"/ledger"},
+		Labels: map[string]string{Label: "accessor"},
+		Mounts: []engine.Mount{{Type: "volume", Source: l.volume, Target: mountPath}},
+	}
+	create := l.client.ContainerCreate
+	if exclusive {
+		options.Name = "docker-deployment-ledger-" + l.volume + "-lock"
+		create = l.createExclusive
+	}
+
+	containerID, err := create(ctx, options)
+	if err != nil {
+		return fmt.Errorf("error creating history accessor: %w", err)
+	}
+	defer func() {
+		_ = l.client.ContainerRemove(context.Background(), containerID, true)
+	}()
+
+	return fn(containerID)
+}
+
+// createExclusive creates the accessor with the name of options once no other one holds it. An accessor
+// older than staleLockAge is removed first.
+func (l *Ledger) createExclusive(ctx context.Context, options engine.CreateOptions) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+
+	for {
+		containerID, err := l.client.ContainerCreate(ctx, options)
+		if !errors.Is(err, engine.ErrConflict) {
+			return containerID, err
+		}
+
+		if holder, err := l.client.ContainerInspect(ctx, options.Name); err == nil && time.Since(holder.Created) > staleLockAge {
+			_ = l.client.ContainerRemove(ctx, holder.ID, true)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("history is locked by %s: %w", options.Name, ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// ensureAccessorImage imports an empty image, so reading the ledger needs no registry access.
+func (l *Ledger) ensureAccessorImage(ctx context.Context) error {
+	_, err := l.client.ImageInspect(ctx, accessorImage+":"+accessorTag)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, engine.ErrNotFound) {
+		return err
+	}
+
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	if err := writer.WriteHeader(&tar.Header{Name: "ledger/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return l.client.ImageImport(ctx, &archive, accessorImage, accessorTag)
+}
+
+// readFile returns the content of a file in the container, or nil when it does not exist.
+func (l *Ledger) readFile(ctx context.Context, containerID string, filePath string) ([]byte, error) {
+	archive, err := l.client.CopyFromContainer(ctx, containerID, filePath)
+	if errors.Is(err, engine.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	reader := tar.NewReader(archive)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg {
+			return io.ReadAll(reader)
+		}
+	}
+}
+
+// writeFiles writes the files, relative to the ledger root, into the volume.
+func (l *Ledger) writeFiles(ctx context.Context, containerID string, files map[string][]byte) error {
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	now := time.Now()
+
+	if hasFileIn(files, composeDir) {
+		if err := writer.WriteHeader(&tar.Header{Name: composeDir + "/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: now}); err != nil {
+			return err
+		}
+	}
+
+	for name, content := range files {
+		header := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content)), ModTime: now}
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := writer.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return l.client.CopyToContainer(ctx, containerID, mountPath, &archive)
+}
+
+func hasFileIn(files map[string][]byte, dir string) bool {
+	for name := range files {
+		if path.Dir(name) == dir {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package history
+
+import (
+	"context"
+	"docker-deployment/src/engine"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockDaemon answers container create, inspect and remove calls, keeping one container per name.
+type lockDaemon struct {
+	mutex   sync.Mutex
+	created map[string]time.Time
+	removed []string
+}
+
+func (d *lockDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/"+engine.APIVersion)
+	switch {
+	case r.Method == http.MethodPost && path == "/containers/create":
+		name := r.URL.Query().Get("name")
+		if _, ok := d.created[name]; ok {
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(map[string]string{"message": "name " + name + " already in use"})
+			return
+		}
+		d.created[name] = time.Now()
+		_ = json.NewEncoder(w).Encode(map[string]string{"Id": name})
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/json"):
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "/containers/"), "/json")
+		created, ok := d.created[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(engine.ContainerJSON{ID: name, Name: "/" + name, Created: created})
+	case r.Method == http.MethodDelete:
+		name := strings.TrimPrefix(path, "/containers/")
+		delete(d.created, name)
+		d.removed = append(d.removed, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newLockLedger(t *testing.T, daemon *lockDaemon) *Ledger {
+	t.Helper()
+
+	server := httptest.NewServer(daemon)
+	t.Cleanup(server.Close)
+
+	client, err := engine.New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Ledger{client: client, volume: DefaultVolume}
+}
+
+func TestCreateExclusiveWaitsForHolder(t *testing.T) {
+	daemon := &lockDaemon{created: map[string]time.Time{"lock": time.Now()}}
+	ledger := newLockLedger(t, daemon)
+
+	go func() {
+		time.Sleep(2 * lockRetryInterval)
+		_ = ledger.client.ContainerRemove(context.Background(), "lock", true)
+	}()
+
+	started := time.Now()
+	containerID, err := ledger.createExclusive(context.Background(), engine.CreateOptions{Name: "lock"})
+	if err != nil {
+		t.Fatalf("createExclusive() = %v", err)
+	}
+	if containerID != "lock" {
+		t.Errorf("createExclusive() = %s, want lock", containerID)
+	}
+	if time.Since(started) < 2*lockRetryInterval {
+		t.Errorf("lock taken before its holder released it")
+	}
+}
+
+func TestCreateExclusiveRemovesStaleLock(t *testing.T) {
+	daemon := &lockDaemon{created: map[string]time.Time{"lock": time.Now().Add(-2 * staleLockAge)}}
+	ledger := newLockLedger(t, daemon)
+
+	if _, err := ledger.createExclusive(context.Background(), engine.CreateOptions{Name: "lock"}); err != nil {
+		t.Fatalf("createExclusive() = %v", err)
+	}
+	if len(daemon.removed) != 1 || daemon.removed[0] != "lock" {
+		t.Errorf("removed = %v, want the stale lock", daemon.removed)
+	}
+}
+
+func TestCreateExclusiveGivesUpWithContext(t *testing.T) {
+	daemon := &lockDaemon{created: map[string]time.Time{"lock": time.Now()}}
+	ledger := newLockLedger(t, daemon)
+
+	ctx, cancel := context.WithTimeout(context.Background(), lockRetryInterval)
+	defer cancel()
+	if _, err := ledger.createExclusive(ctx, engine.CreateOptions{Name: "lock"}); err == nil {
+		t.Fatal("createExclusive() took a held lock")
+	}
+}
@@ -3,59 +3,67 @@ package logger
 import (
 	"bufio"
 	"context"
+	"docker-deployment/src/engine"
 	"docker-deployment/src/utils"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"sync"
 	"time"
 )
 
-func GetPodLogs(ctx context.Context, dockerComposeFile string) error {
+func GetPodLogs(ctx context.Context, project string) error {
 	time.Sleep(2 * time.Second)
-	cmd := exec.Command("docker-compose", "-f", dockerComposeFile, "logs", "-f")
-	utils.Logger(utils.ColorBlue, "Getting %s logs", dockerComposeFile)
-
-	// Create a pipe to capture the command output
-	stdoutPipe, err := cmd.StdoutPipe()
-
+	client, err := engine.Default()
 	if err != nil {
-		return fmt.Errorf("failed to get stdout pipe: %s", err)
+		return err
 	}
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start command: %s", err)
+	utils.Logger(utils.ColorBlue, "Getting %s logs", project)
+
+	containers, err := client.ContainerList(ctx, true, engine.ProjectFilter(project))
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %s", err)
 	}
 
-	// Read and print logs from stdout in a goroutine
-	go func() {
-		scanner := bufio.NewScanner(stdoutPipe)
-		for scanner.Scan() {
-			utils.Logger("", scanner.Text())
+	var wg sync.WaitGroup
+	for _, container := range containers {
+		logs, err := client.ContainerLogs(ctx, container.ID, engine.LogsOptions{Follow: true})
+		if err != nil {
+			return fmt.Errorf("failed to get logs of %s: %s", container.Name(), err)
 		}
-		if err := scanner.Err(); err != nil {
-			utils.Logger(utils.ColorRed, "Error reading logs: %s", err)
-		}
-	}()
 
-	// Wait for the context to be done or the command to finish
+		// Read and print logs in a goroutine, prefixed like docker-compose does
+		wg.Add(1)
+		go func(name string, logs io.ReadCloser) {
+			defer wg.Done()
+			defer logs.Close()
+			scanner := bufio.NewScanner(logs)
+			for scanner.Scan() {
+				utils.Logger("", "%s  | %s", name, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil && ctx.Err() == nil {
+				utils.Logger(utils.ColorRed, "Error reading logs: %s", err)
+			}
+		}(container.Name(), logs)
+	}
+
+	// Wait for the context to be done or every log stream to finish
 	select {
 	case <-ctx.Done():
-		// Context is done, cancel logs retrieval
-		if err := cmd.Process.Kill(); err != nil {
-			utils.Logger(utils.ColorRed, "Failed to kill logs process: %s", err)
-		}
+		// Context is done, the log streams are closed with it
 		return ctx.Err()
-	case err := <-waitCmd(cmd):
-		// Command finished
-		return err
+	case <-waitGroup(&wg):
+		return nil
 	}
 }
 
-// Helper function to wait for command completion
-func waitCmd(cmd *exec.Cmd) <-chan error {
-	done := make(chan error)
+// Helper function to wait for log streams completion
+func waitGroup(wg *sync.WaitGroup) <-chan struct{} {
+	done := make(chan struct{})
 	go func() {
-		done <- cmd.Wait()
+		wg.Wait()
+		close(done)
 	}()
 	return done
 }
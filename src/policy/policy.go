@@ -0,0 +1,258 @@
+package policy
+
+import (
+	"docker-deployment/src/utils"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Rules evaluated against the services of a compose file.
+const (
+	RuleLatestTag         = "latest_tag"
+	RuleUntaggedImage     = "untagged_image"
+	RuleRegistryAllowlist = "registry_allowlist"
+	RuleContainerName     = "container_name"
+	RuleHealthCheck       = "healthcheck"
+	RulePrivileged        = "privileged"
+	RuleHostNetwork       = "host_network"
+	RuleDockerSocket      = "docker_socket"
+)
+
+// Levels a rule can be set to.
+const (
+	LevelOff  = "off"
+	LevelWarn = "warn"
+	LevelDeny = "deny"
+)
+
+// rules lists every rule in the order violations are reported.
+var rules = []string{
+	RuleLatestTag,
+	RuleUntaggedImage,
+	RuleRegistryAllowlist,
+	RuleContainerName,
+	RuleHealthCheck,
+	RulePrivileged,
+	RuleHostNetwork,
+	RuleDockerSocket,
+}
+
+// dockerSockets are the paths of the Docker daemon socket, whose mount gives control of the host.
+var dockerSockets = []string{"/var/run/docker.sock", "/run/docker.sock"}
+
+// Policy sets the level of each rule. Rules missing from Rules are warnings.
+type Policy struct {
+	Rules map[string]string `yaml:"rules"`
+	// AllowedRegistries are the registries images may come from, any registry when empty. They are compared
+	// with the Registry of services, so both must be normalized the same way.
+	AllowedRegistries []string `yaml:"allowed_registries"`
+}
+
+// Service is what the rules know about a compose service.
+type Service struct {
+	Name           string
+	Image          string
+	Registry       string
+	ContainerName  string
+	HasHealthCheck bool
+	Privileged     bool
+	NetworkMode    string
+	// Mounts are the host paths bind mounted in the container
+	Mounts []string
+}
+
+// Violation is a rule a service breaks.
+type Violation struct {
+	Rule    string
+	Level   string
+	Service string
+	Message string
+}
+
+// Report lists the violations found in a compose file.
+type Report struct {
+	Violations []Violation
+}
+
+// Default returns the policy used without policy file: every rule is a warning.
+func Default() *Policy {
+	return &Policy{Rules: map[string]string{}}
+}
+
+// Load reads a policy file, returning an error for unknown rules or levels.
+func Load(path string) (*Policy, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := Default()
+	if err := yaml.Unmarshal(content, policy); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	if policy.Rules == nil {
+		policy.Rules = map[string]string{}
+	}
+
+	for rule, level := range policy.Rules {
+		if !known(rule) {
+			return nil, fmt.Errorf("unknown rule %q in %s, expected one of %s", rule, path, strings.Join(rules, ", "))
+		}
+		switch level {
+		case LevelOff, LevelWarn, LevelDeny:
+		default:
+			return nil, fmt.Errorf("unknown level %q of rule %s in %s, expected %s, %s or %s", level, rule, path,
+				LevelOff, LevelWarn, LevelDeny)
+		}
+	}
+	return policy, nil
+}
+
+func known(rule string) bool {
+	for _, candidate := range rules {
+		if candidate == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// level returns the level of a rule.
+func (p *Policy) level(rule string) string {
+	if level, ok := p.Rules[rule]; ok {
+		return level
+	}
+	return LevelWarn
+}
+
+// Evaluate checks every service against the rules of the policy.
+func (p *Policy) Evaluate(services []Service) *Report {
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+
+	report := &Report{}
+	for _, rule := range rules {
+		level := p.level(rule)
+		if level == LevelOff {
+			continue
+		}
+		for _, service := range services {
+			if message := p.check(rule, service); message != "" {
+				report.Violations = append(report.Violations, Violation{Rule: rule, Level: level, Service: service.Name, Message: message})
+			}
+		}
+	}
+	return report
+}
+
+// check returns why the service breaks the rule, or an empty string when it does not.
+func (p *Policy) check(rule string, service Service) string {
+	switch rule {
+	case RuleLatestTag:
+		if tag := imageTag(service.Image); tag == "latest" {
+			return fmt.Sprintf("image %s uses the latest tag", service.Image)
+		}
+	case RuleUntaggedImage:
+		if service.Image != "" && imageTag(service.Image) == "" && !strings.Contains(service.Image, "@") {
+			return fmt.Sprintf("image %s has no tag", service.Image)
+		}
+	case RuleRegistryAllowlist:
+		if service.Image == "" || len(p.AllowedRegistries) == 0 {
+			return ""
+		}
+		for _, registry := range p.AllowedRegistries {
+			if registry == service.Registry {
+				return ""
+			}
+		}
+		return fmt.Sprintf("image %s comes from %s, which is not an allowed registry", service.Image, service.Registry)
+	case RuleContainerName:
+		if service.ContainerName == "" {
+			return "no container_name"
+		}
+	case RuleHealthCheck:
+		if !service.HasHealthCheck {
+			return "no healthcheck"
+		}
+	case RulePrivileged:
+		if service.Privileged {
+			return "runs privileged"
+		}
+	case RuleHostNetwork:
+		if service.NetworkMode == "host" {
+			return "uses the host network"
+		}
+	case RuleDockerSocket:
+		for _, mount := range service.Mounts {
+			socket := dockerSocket(mount)
+			switch {
+			case socket == "":
+			case socket == filepath.Clean(os.ExpandEnv(mount)):
+				return fmt.Sprintf("mounts the Docker socket %s", mount)
+			default:
+				return fmt.Sprintf("mounts %s, which holds the Docker socket %s", mount, socket)
+			}
+		}
+	}
+	return ""
+}
+
+// dockerSocket returns the Docker socket reachable through a host path mounted in a container, either the
+// socket itself or a directory above it, or an empty string. Environment variables in the path are expanded
+// first, as compose does.
+func dockerSocket(mount string) string {
+	mount = filepath.Clean(os.ExpandEnv(mount))
+	for _, socket := range dockerSockets {
+		if socket == mount || strings.HasPrefix(socket, strings.TrimSuffix(mount, "/")+"/") {
+			return socket
+		}
+	}
+	return ""
+}
+
+// imageTag returns the tag of an image reference, empty when it has none.
+func imageTag(image string) string {
+	name, _, _ := strings.Cut(image, "@")
+	lastPart := name[strings.LastIndex(name, "/")+1:]
+	if _, tag, ok := strings.Cut(lastPart, ":"); ok {
+		return tag
+	}
+	return ""
+}
+
+// Denied returns the violations of rules set to deny.
+func (r *Report) Denied() []Violation {
+	var denied []Violation
+	for _, violation := range r.Violations {
+		if violation.Level == LevelDeny {
+			denied = append(denied, violation)
+		}
+	}
+	return denied
+}
+
+// Print prints the violations, denied ones first.
+func (r *Report) Print() {
+	if len(r.Violations) == 0 {
+		utils.Logger(utils.ColorGreen, "Policy: no violations")
+		return
+	}
+
+	denied := r.Denied()
+	utils.Logger(utils.ColorBlue, "Policy violations:")
+	for _, level := range []string{LevelDeny, LevelWarn} {
+		color := utils.ColorYellow
+		if level == LevelDeny {
+			color = utils.ColorRed
+		}
+		for _, violation := range r.Violations {
+			if violation.Level == level {
+				utils.Logger(color, "  %-5s %-20s %-20s %s", violation.Level, violation.Rule, violation.Service, violation.Message)
+			}
+		}
+	}
+	utils.Logger(utils.ColorBlue, "Policy: %d denied, %d warnings", len(denied), len(r.Violations)-len(denied))
+}
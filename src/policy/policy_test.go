@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	compliant := Service{Name: "web", Image: "nginx:1.27", Registry: "docker.io", ContainerName: "web", HasHealthCheck: true}
+
+	tests := []struct {
+		name    string
+		policy  *Policy
+		service func(Service) Service
+		want    []Violation
+	}{
+		{
+			name:    "compliant",
+			policy:  Default(),
+			service: func(s Service) Service { return s },
+		},
+		{
+			name:    "latest tag",
+			policy:  Default(),
+			service: func(s Service) Service { s.Image = "nginx:latest"; return s },
+			want:    []Violation{{Rule: RuleLatestTag, Level: LevelWarn, Service: "web", Message: "image nginx:latest uses the latest tag"}},
+		},
+		{
+			name:    "untagged image",
+			policy:  &Policy{Rules: map[string]string{RuleUntaggedImage: LevelDeny}},
+			service: func(s Service) Service { s.Image = "registry.example.com:5000/nginx"; return s },
+			want: []Violation{{Rule: RuleUntaggedImage, Level: LevelDeny, Service: "web",
+				Message: "image registry.example.com:5000/nginx has no tag"}},
+		},
+		{
+			name:    "digest is not untagged",
+			policy:  Default(),
+			service: func(s Service) Service { s.Image = "nginx@sha256:1111"; return s },
+		},
+		{
+			name:    "registry not allowed",
+			policy:  &Policy{Rules: map[string]string{}, AllowedRegistries: []string{"ghcr.io"}},
+			service: func(s Service) Service { return s },
+			want: []Violation{{Rule: RuleRegistryAllowlist, Level: LevelWarn, Service: "web",
+				Message: "image nginx:1.27 comes from docker.io, which is not an allowed registry"}},
+		},
+		{
+			name:    "registry allowed",
+			policy:  &Policy{Rules: map[string]string{}, AllowedRegistries: []string{"ghcr.io", "docker.io"}},
+			service: func(s Service) Service { return s },
+		},
+		{
+			name:   "rules turned off",
+			policy: &Policy{Rules: map[string]string{RuleContainerName: LevelOff, RuleHealthCheck: LevelOff}},
+			service: func(s Service) Service {
+				s.ContainerName, s.HasHealthCheck = "", false
+				return s
+			},
+		},
+		{
+			name:   "privileged, host network and docker socket",
+			policy: &Policy{Rules: map[string]string{RuleDockerSocket: LevelDeny}},
+			service: func(s Service) Service {
+				s.Privileged, s.NetworkMode, s.Mounts = true, "host", []string{"/srv/data", "/var/run/docker.sock"}
+				return s
+			},
+			want: []Violation{
+				{Rule: RulePrivileged, Level: LevelWarn, Service: "web", Message: "runs privileged"},
+				{Rule: RuleHostNetwork, Level: LevelWarn, Service: "web", Message: "uses the host network"},
+				{Rule: RuleDockerSocket, Level: LevelDeny, Service: "web", Message: "mounts the Docker socket /var/run/docker.sock"},
+			},
+		},
+		{
+			name:    "parent of the docker socket",
+			policy:  Default(),
+			service: func(s Service) Service { s.Mounts = []string{"/var/run"}; return s },
+			want: []Violation{{Rule: RuleDockerSocket, Level: LevelWarn, Service: "web",
+				Message: "mounts /var/run, which holds the Docker socket /var/run/docker.sock"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := test.policy.Evaluate([]Service{test.service(compliant)})
+			if !reflect.DeepEqual(report.Violations, test.want) {
+				t.Errorf("violations = %+v, want %+v", report.Violations, test.want)
+			}
+		})
+	}
+}
+
+func TestReportDenied(t *testing.T) {
+	policy := &Policy{Rules: map[string]string{RuleHealthCheck: LevelDeny}}
+	report := policy.Evaluate([]Service{
+		{Name: "web", Image: "nginx:1.27", ContainerName: "web"},
+		{Name: "api", Image: "api:latest", ContainerName: "api", HasHealthCheck: true},
+	})
+
+	denied := report.Denied()
+	if len(denied) != 1 || denied[0].Service != "web" || denied[0].Rule != RuleHealthCheck {
+		t.Errorf("denied = %+v", denied)
+	}
+	if len(report.Violations) != 2 {
+		t.Errorf("violations = %+v", report.Violations)
+	}
+}
+
+func TestDockerSocket(t *testing.T) {
+	t.Setenv("DOCKER_SOCK", "/var/run/docker.sock")
+
+	tests := []struct {
+		mount string
+		want  string
+	}{
+		{"/var/run/docker.sock", "/var/run/docker.sock"},
+		{"/run/docker.sock", "/run/docker.sock"},
+		{"${DOCKER_SOCK}", "/var/run/docker.sock"},
+		{"/srv/../run/./docker.sock", "/run/docker.sock"},
+		{"/var/run/", "/var/run/docker.sock"},
+		{"/var", "/var/run/docker.sock"},
+		{"/", "/var/run/docker.sock"},
+		{"/var/run/docker.sock.bak", ""},
+		{"/var/running", ""},
+		{"/srv/data", ""},
+	}
+
+	for _, test := range tests {
+		if got := dockerSocket(test.mount); got != test.want {
+			t.Errorf("dockerSocket(%q) = %q, want %q", test.mount, got, test.want)
+		}
+	}
+}
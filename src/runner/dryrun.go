@@ -0,0 +1,26 @@
+package runner
+
+import (
+	"context"
+	"docker-deployment/src/utils"
+)
+
+// DryRun prints the commands that would be executed instead of running them.
+// Read-only commands are still executed by the wrapped runner.
+type DryRun struct {
+	Runner Runner
+}
+
+// NewDryRun returns a dry-run runner executing read-only commands with runner.
+func NewDryRun(runner Runner) *DryRun {
+	return &DryRun{Runner: runner}
+}
+
+func (d *DryRun) Run(ctx context.Context, command Command) ([]byte, error) {
+	if command.ReadOnly && d.Runner != nil {
+		return d.Runner.Run(ctx, command)
+	}
+
+	utils.Logger(utils.ColorYellow, "[dry-run] %s", command.String())
+	return nil, nil
+}
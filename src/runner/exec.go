@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// Exec runs commands as local processes.
+type Exec struct{}
+
+func (Exec) Run(ctx context.Context, command Command) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, command.Name, command.Args...)
+	cmd.Stdin = command.Stdin
+
+	var out bytes.Buffer
+	var writer io.Writer = &out
+	if command.Output != nil {
+		writer = io.MultiWriter(&out, command.Output)
+	}
+
+	// Stdout and stderr share the writer, which must be safe for concurrent use
+	synced := &syncWriter{writer: writer}
+	cmd.Stdout = synced
+	cmd.Stderr = synced
+
+	err := cmd.Run()
+	return out.Bytes(), err
+}
+
+type syncWriter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.writer.Write(p)
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Command is an external process to run.
+type Command struct {
+	Name string
+	Args []string
+	// Stdin is fed to the process when not nil.
+	Stdin io.Reader
+	// Output receives the combined output while the process runs, when not nil.
+	Output io.Writer
+	// ReadOnly marks commands that do not change anything, which a dry run still executes.
+	ReadOnly bool
+}
+
+// String returns the command line.
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// Runner executes commands.
+type Runner interface {
+	// Run executes the command and returns its combined output.
+	Run(ctx context.Context, command Command) ([]byte, error)
+}
+
+var (
+	defaultRunner Runner = Exec{}
+	defaultMutex  sync.RWMutex
+)
+
+// Default returns the runner used by the package level functions.
+func Default() Runner {
+	defaultMutex.RLock()
+	defer defaultMutex.RUnlock()
+	return defaultRunner
+}
+
+// SetDefault replaces the runner used by the package level functions.
+func SetDefault(runner Runner) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	defaultRunner = runner
+}
+
+// Run executes the command with the default runner.
+func Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return Default().Run(ctx, Command{Name: name, Args: args})
+}
+
+// RunCommand executes a fully described command with the default runner.
+func RunCommand(ctx context.Context, command Command) ([]byte, error) {
+	return Default().Run(ctx, command)
+}
+
+// IsDryRun reports whether the default runner only prints what would be executed.
+func IsDryRun() bool {
+	_, ok := Default().(*DryRun)
+	return ok
+}
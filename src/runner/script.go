@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Response is the scripted result of a command.
+type Response struct {
+	Output string
+	Err    error
+}
+
+type expectation struct {
+	prefix    string
+	responses []Response
+}
+
+// Script is a fake runner that records every command and answers with scripted responses,
+// so flows that shell out can run without the real binaries.
+type Script struct {
+	mutex        sync.Mutex
+	expectations []*expectation
+	recorded     []Command
+	// Fallback answers commands that match no expectation; they fail when it is nil.
+	Fallback *Response
+}
+
+// NewScript returns an empty script.
+func NewScript() *Script {
+	return &Script{}
+}
+
+// Expect answers the commands whose command line starts with prefix. Several responses are
+// returned in order, the last one repeating once the others are used.
+func (s *Script) Expect(prefix string, responses ...Response) *Script {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(responses) == 0 {
+		responses = []Response{{}}
+	}
+	s.expectations = append(s.expectations, &expectation{prefix: prefix, responses: responses})
+	return s
+}
+
+// Recorded returns the commands run so far.
+func (s *Script) Recorded() []Command {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Command(nil), s.recorded...)
+}
+
+func (s *Script) Run(_ context.Context, command Command) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.recorded = append(s.recorded, command)
+	line := command.String()
+
+	// The longest matching prefix wins so specific expectations override generic ones
+	var match *expectation
+	for _, candidate := range s.expectations {
+		if strings.HasPrefix(line, candidate.prefix) && (match == nil || len(candidate.prefix) > len(match.prefix)) {
+			match = candidate
+		}
+	}
+
+	var response Response
+	switch {
+	case match != nil:
+		response = match.responses[0]
+		if len(match.responses) > 1 {
+			match.responses = match.responses[1:]
+		}
+	case s.Fallback != nil:
+		response = *s.Fallback
+	default:
+		return nil, fmt.Errorf("unexpected command: %s", line)
+	}
+
+	if command.Output != nil && response.Output != "" {
+		_, _ = command.Output.Write([]byte(response.Output))
+	}
+	return []byte(response.Output), response.Err
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"docker-deployment/src/compose"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/history"
+	"docker-deployment/src/utils"
+	"docker-deployment/src/validation"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	colourBlue  = "blue"
+	colourGreen = "green"
+)
+
+// blueGreenRun starts the new version next to the live one, validates it and only then swaps it in.
+func blueGreenRun(config Config, timeout time.Duration) error {
+	_ = Prune()
+
+	current, err := prepareDeployment(config.ComposeFile, "")
+	if err != nil {
+		return err
+	}
+
+	project := config.projectName()
+	liveColour := activeColour(project)
+	nextColour := colourBlue
+	if liveColour == colourBlue {
+		nextColour = colourGreen
+	}
+
+	liveContainers, err := liveColourContainers(project, liveColour, current.Services)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error finding live containers: %s", err)
+		return exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	colourProject := fmt.Sprintf("%s-%s", project, nextColour)
+	colourPath := filepath.Join(current.Dir, colourProject, "docker-compose.yaml")
+
+	utils.Logger(utils.ColorBlue, "Live colour: %s, deploying %s", colourOrLegacy(liveColour), nextColour)
+
+	// Candidate: coloured names, no published host ports so it can run next to the live colour
+	err = writeColourCompose(current.ComposePath, colourPath, nextColour, false)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error writing %s compose file: %s", nextColour, err)
+		return exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	if err = colourUp(colourProject, colourPath, config.Force, current.Services.upServices()...); err != nil {
+		utils.Logger(utils.ColorRed, "Error starting %s: %s", nextColour, err)
+		colourDown(colourProject, colourPath)
+		return exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	candidate, err := GetContainers(colourProject)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error getting containers: %s", err)
+		colourDown(colourProject, colourPath)
+		return exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	if err = validateDeployment(candidate, colourProject, colourPath, config.ComposeFile, timeout); err != nil {
+		utils.Logger(utils.ColorRed, "Health check error on %s: %s", nextColour, err)
+		colourDown(colourProject, colourPath)
+		utils.Logger(utils.ColorYellow, "Deployment failed, %s is still live", colourOrLegacy(liveColour))
+		return exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	utils.Logger(utils.ColorGreen, "Colour %s is healthy, swapping it in...", nextColour)
+
+	// Swap: publish the real ports on the new colour while the live colour is stopped. Host ports can only
+	// be published by one container and publishing them recreates the candidate containers, so this is a
+	// known downtime window: from stopping the live colour until the recreated containers, which are not
+	// the ones validated above, pass validation again below
+	err = writeColourCompose(current.ComposePath, colourPath, nextColour, true)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error writing %s compose file: %s", nextColour, err)
+		colourDown(colourProject, colourPath)
+		return exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	client, err := engine.Default()
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error connecting to docker: %s", err)
+		colourDown(colourProject, colourPath)
+		return exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	// Exited containers, like the one-shot jobs of the live colour, are left alone so a swap back does not
+	// run them again
+	downSince := time.Now()
+	stopped := map[string]string{}
+	for name, containerID := range liveContainers {
+		inspect, err := client.ContainerInspect(context.Background(), containerID)
+		if err != nil {
+			utils.Logger(utils.ColorRed, "Error inspecting %s: %s", name, err)
+			return swapBack(colourProject, colourPath, stopped, liveColour, timeout)
+		}
+		if !inspect.State.Running {
+			continue
+		}
+		utils.Logger(utils.ColorYellow, "Stopping %s (%s)", name, utils.GetShortId(containerID))
+		if err := client.ContainerStop(context.Background(), containerID); err != nil {
+			utils.Logger(utils.ColorRed, "Error stopping %s: %s", name, err)
+			return swapBack(colourProject, colourPath, stopped, liveColour, timeout)
+		}
+		stopped[name] = containerID
+	}
+
+	if err = colourUp(colourProject, colourPath, false, current.Services.upServices()...); err != nil {
+		utils.Logger(utils.ColorRed, "Error swapping in %s: %s", nextColour, err)
+		return swapBack(colourProject, colourPath, stopped, liveColour, timeout)
+	}
+
+	swapped, err := GetContainers(colourProject)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error getting containers: %s", err)
+		return swapBack(colourProject, colourPath, stopped, liveColour, timeout)
+	}
+
+	if err = validateDeployment(swapped, colourProject, colourPath, config.ComposeFile, timeout); err != nil {
+		utils.Logger(utils.ColorRed, "Health check error after swap: %s", err)
+		return swapBack(colourProject, colourPath, stopped, liveColour, timeout)
+	}
+	utils.Logger(utils.ColorYellow, "Live service was down for %s during the swap", time.Since(downSince).Round(time.Millisecond))
+
+	if err = runSmokeTests(colourProject, colourPath, swapped); err != nil {
+		utils.Logger(utils.ColorRed, "Smoke test error after swap: %s", err)
+		return swapBack(colourProject, colourPath, stopped, liveColour, timeout)
+	}
+
+	for name, containerID := range liveContainers {
+		if err := client.ContainerRemove(context.Background(), containerID, true); err != nil {
+			utils.Logger(utils.ColorRed, "Failed to remove %s (%s): %s", name, utils.GetShortId(containerID), err)
+		}
+	}
+
+	recordImages(swapped)
+	_ = Prune()
+	utils.Logger(utils.ColorGreen, "Colour %s is live", nextColour)
+	finishRecord(history.OutcomeSuccess, fmt.Sprintf("colour %s is live", nextColour))
+	return nil
+}
+
+// swapBack removes the new colour, restarts the live containers stopped for the swap, validates them and
+// returns the error of the failed deployment.
+func swapBack(colourProject string, colourPath string, stopped map[string]string, liveColour string, timeout time.Duration) error {
+	// The settings of the services, like which ones are one-shot jobs, come from the compose file
+	options, err := healthOptions(colourPath)
+	if err != nil {
+		options = validationOptions
+	}
+	colourDown(colourProject, colourPath)
+
+	if len(stopped) == 0 {
+		utils.Logger(utils.ColorYellow, "Deployment failed, no live containers were stopped")
+		return exitDeployment(history.OutcomeFailed, "swap failed before any live container was stopped")
+	}
+
+	client, err := engine.Default()
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error connecting to docker: %s", err)
+		return exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	for name, containerID := range stopped {
+		if err := client.ContainerStart(context.Background(), containerID); err != nil {
+			utils.Logger(utils.ColorRed, "Error restarting %s: %s", name, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err := validation.ValidateHealthCheck(ctx, timeout, stopped, colourOrLegacy(liveColour), options); err != nil {
+		utils.Logger(utils.ColorRed, "Swap back to %s failed health check: %s", colourOrLegacy(liveColour), err)
+		return exitDeployment(history.OutcomeFailed, fmt.Sprintf("swap back to %s failed health check: %s", colourOrLegacy(liveColour), err))
+	}
+
+	utils.Logger(utils.ColorYellow, "Deployment failed, %s is live again", colourOrLegacy(liveColour))
+	return exitDeployment(history.OutcomeRolledBack, fmt.Sprintf("%s is live again", colourOrLegacy(liveColour)))
+}
+
+// colourUp starts the compose file of a colour. When services are given only those are started, without
+// their dependencies.
+func colourUp(colourProject string, colourPath string, force bool, services ...string) error {
+	cmdArgs := compose.ProjectArgs(colourProject, colourPath, "up", "-d", "--remove-orphans")
+	if force {
+		cmdArgs = append(cmdArgs, "--force-recreate")
+	}
+	if len(services) > 0 {
+		cmdArgs = append(cmdArgs, "--no-deps")
+		cmdArgs = append(cmdArgs, services...)
+	}
+
+	utils.Logger(utils.ColorBlue, "Starting docker-compose...")
+	if output, err := compose.Run(context.Background(), cmdArgs...); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func colourDown(colourProject string, colourPath string) {
+	if output, err := compose.Run(context.Background(), compose.ProjectArgs(colourProject, colourPath, "down", "--remove-orphans")...); err != nil {
+		utils.Logger(utils.ColorRed, "Error removing %s: %s", colourProject, string(output))
+	}
+}
+
+// activeColour returns the colour whose project has running containers, or "" when none does.
+func activeColour(project string) string {
+	for _, colour := range []string{colourBlue, colourGreen} {
+		if len(projectContainers(fmt.Sprintf("%s-%s", project, colour), false)) > 0 {
+			return colour
+		}
+	}
+	return ""
+}
+
+// liveColourContainers returns the containers serving traffic, including those deployed before blue-green was enabled.
+func liveColourContainers(project string, liveColour string, services *Services) (map[string]string, error) {
+	live := make(map[string]string)
+
+	if liveColour != "" {
+		for _, containerID := range projectContainers(fmt.Sprintf("%s-%s", project, liveColour), true) {
+			inspect, err := inspectContainer(containerID)
+			if err != nil {
+				return nil, err
+			}
+			if inspect != nil {
+				live[inspect.ShortName()] = inspect.ID
+			}
+		}
+		return live, nil
+	}
+
+	for _, name := range services.activeNames() {
+		svc := services.Services[name]
+		if svc.ContainerName == "" {
+			continue
+		}
+		inspect, err := inspectContainer(svc.ContainerName)
+		if err != nil {
+			return nil, err
+		}
+		if inspect != nil {
+			live[svc.ContainerName] = inspect.ID
+		}
+	}
+
+	return live, nil
+}
+
+func projectContainers(project string, all bool) []string {
+	client, err := engine.Default()
+	if err != nil {
+		return nil
+	}
+
+	containers, err := client.ContainerList(context.Background(), all, engine.ProjectFilter(project))
+	if err != nil {
+		return nil
+	}
+
+	var ids []string
+	for _, container := range containers {
+		ids = append(ids, container.ID)
+	}
+	return ids
+}
+
+// writeColourCompose writes the compose file for a colour, suffixing container names and, unless
+// publishPorts is set, dropping host port bindings so both colours can run side by side.
+func writeColourCompose(source string, destination string, colour string, publishPorts bool) error {
+	return rewriteCompose(source, destination, func(serviceName string, serviceNode *yaml.Node) {
+		if containerName := mappingValue(serviceNode, "container_name"); containerName != nil {
+			setMappingValue(serviceNode, "container_name", fmt.Sprintf("%s-%s", containerName.Value, colour))
+		}
+		if !publishPorts {
+			unpublishPorts(mappingValue(serviceNode, "ports"))
+		}
+	})
+}
+
+// unpublishPorts keeps only the container side of every port so Docker picks free host ports.
+func unpublishPorts(portsNode *yaml.Node) {
+	if portsNode == nil || portsNode.Kind != yaml.SequenceNode {
+		return
+	}
+
+	for _, port := range portsNode.Content {
+		switch port.Kind {
+		case yaml.ScalarNode:
+			parts := strings.Split(port.Value, ":")
+			port.Value = parts[len(parts)-1]
+			port.Tag = "!!str"
+			port.Style = yaml.DoubleQuotedStyle
+		case yaml.MappingNode:
+			deleteMappingValue(port, "published")
+			deleteMappingValue(port, "host_ip")
+		}
+	}
+}
+
+func colourOrLegacy(colour string) string {
+	if colour == "" {
+		return "the previous deployment"
+	}
+	return colour
+}
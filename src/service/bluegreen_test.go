@@ -0,0 +1,66 @@
+package service
+
+import (
+	"docker-deployment/src/compose"
+	"docker-deployment/src/history"
+	"docker-deployment/src/runner"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const colourCompose = `services:
+  web:
+    image: nginx:1.27
+    container_name: web-blue
+    healthcheck:
+      test: ["CMD", "true"]
+      interval: 1s
+  migrate:
+    image: migrate:1.0
+    restart: "no"
+`
+
+func TestSwapBackRestartsStoppedContainersOnly(t *testing.T) {
+	daemon := startFakeDaemon(t,
+		&fakeContainer{ID: "0123456789ab", Name: "web-blue", Project: "shop-blue", Service: "web", Image: "nginx:1.26", Health: "healthy"},
+		&fakeContainer{ID: "ba9876543210", Name: "shop-blue-migrate-1", Project: "shop-blue", Service: "migrate", Image: "migrate:0.9"},
+	)
+	compose.Use(&compose.Binary{Kind: compose.Plugin, Name: "docker", Args: []string{"compose"}})
+	runner.SetDefault(runner.NewScript().Expect("docker compose "))
+	t.Cleanup(func() { runner.SetDefault(runner.Exec{}) })
+
+	colourPath := filepath.Join(t.TempDir(), "docker-compose.yaml")
+	if err := os.WriteFile(colourPath, []byte(colourCompose), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := swapBack("shop-green", colourPath, map[string]string{"web-blue": "0123456789ab"}, colourBlue, 10*time.Second)
+	if outcome := deploymentOutcome(t, err); outcome != history.OutcomeRolledBack {
+		t.Errorf("outcome = %s, want %s (%v)", outcome, history.OutcomeRolledBack, err)
+	}
+
+	var started []string
+	for _, request := range daemon.Requests() {
+		if strings.HasSuffix(request, "/start") {
+			started = append(started, request)
+		}
+	}
+	if len(started) != 1 || started[0] != "POST /containers/0123456789ab/start" {
+		t.Errorf("started = %v, want only the stopped web container", started)
+	}
+}
+
+func TestSwapBackWithoutStoppedContainers(t *testing.T) {
+	startFakeDaemon(t)
+	compose.Use(&compose.Binary{Kind: compose.Plugin, Name: "docker", Args: []string{"compose"}})
+	runner.SetDefault(runner.NewScript().Expect("docker compose "))
+	t.Cleanup(func() { runner.SetDefault(runner.Exec{}) })
+
+	err := swapBack("shop-green", filepath.Join(t.TempDir(), "docker-compose.yaml"), map[string]string{}, "", 10*time.Second)
+	if outcome := deploymentOutcome(t, err); outcome != history.OutcomeFailed {
+		t.Errorf("outcome = %s, want %s", outcome, history.OutcomeFailed)
+	}
+}
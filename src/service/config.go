@@ -0,0 +1,89 @@
+package service
+
+import (
+	"docker-deployment/src/utils"
+	"docker-deployment/src/validation"
+	"fmt"
+	"time"
+)
+
+// Deployment strategies supported by Start.
+const (
+	StrategyRecreate  = "recreate"
+	StrategyBlueGreen = "blue-green"
+	StrategyRolling   = "rolling"
+)
+
+// Config holds the deployment settings read from the environment.
+type Config struct {
+	ComposeFile string
+	Timeout     string
+	Force       bool
+	Strategy    string
+	Project     string
+	BatchSize   int
+	DryRun      bool
+	// Compose selects the compose implementation: auto, plugin or standalone.
+	Compose string
+	// HistoryVolume is the volume holding the deployment history on the Docker host.
+	HistoryVolume string
+	// StabilityWindow is how long a container without healthcheck must run without restarting, e.g. 30s.
+	StabilityWindow string
+	// DiagnosticLogLines is the number of log lines shown for a container that fails validation.
+	DiagnosticLogLines int
+	// PullConcurrency is the number of images pulled at the same time.
+	PullConcurrency int
+	// PullRetries is the number of extra attempts of a pull failing with a transient registry error.
+	PullRetries int
+	// RegistryHost, RegistryUsername and RegistryPassword are the credentials of one registry.
+	RegistryHost     string
+	RegistryUsername string
+	RegistryPassword string
+	// RegistryCredentialsFile is a YAML or JSON file with the credentials of several registries.
+	RegistryCredentialsFile string
+	// RegistryDockerConfig is a docker config.json whose credentials are used to log in.
+	RegistryDockerConfig string
+	// PolicyFile sets the level of the policy rules checked before deploying.
+	PolicyFile string
+
+	rollbackOf string
+}
+
+func (c Config) validate() error {
+	switch c.Strategy {
+	case "", StrategyRecreate, StrategyBlueGreen, StrategyRolling:
+	default:
+		return fmt.Errorf("unknown deployment strategy %q", c.Strategy)
+	}
+
+	if _, err := c.stabilityWindow(); err != nil {
+		return fmt.Errorf("invalid STABILITY_WINDOW: %w", err)
+	}
+	if c.PullConcurrency < 1 {
+		return fmt.Errorf("invalid PULL_CONCURRENCY %d, at least one image must be pulled at a time", c.PullConcurrency)
+	}
+	if c.PullRetries < 0 {
+		return fmt.Errorf("invalid PULL_RETRIES %d", c.PullRetries)
+	}
+	return nil
+}
+
+// stabilityWindow returns the configured stability window, or the default one.
+func (c Config) stabilityWindow() (time.Duration, error) {
+	if c.StabilityWindow == "" {
+		return validation.DefaultStabilityWindow, nil
+	}
+	seconds, err := parseTimeoutToSeconds(c.StabilityWindow)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// projectName returns the compose project name, defaulting to the compose file directory name.
+func (c Config) projectName() string {
+	if c.Project != "" {
+		return utils.NormalizeProjectName(c.Project)
+	}
+	return utils.ComposeProjectName(c.ComposeFile)
+}
@@ -1,46 +1,35 @@
 package service
 
 import (
-	"bytes"
+	"context"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/runner"
 	"docker-deployment/src/utils"
 	"fmt"
-	"os"
-	"os/exec"
-	"strings"
 )
 
-func GetContainers(dockerComposeFile string) (map[string]string, error) {
-	cmd := exec.Command("docker-compose", "-f", dockerComposeFile, "ps", "-q")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+// GetContainers returns the containers of the compose project by name, limited to services when given.
+func GetContainers(project string, services ...string) (map[string]string, error) {
+	client, err := engine.Default()
+	if err != nil {
 		return nil, err
 	}
 
-	containerIDs := strings.TrimSpace(out.String())
-	if containerIDs == "" {
+	containers, err := client.ContainerList(context.Background(), true, engine.ProjectFilter(project, services...))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(containers) == 0 && !runner.IsDryRun() {
 		return nil, fmt.Errorf("no containers found")
 	}
 
-	idList := strings.Split(containerIDs, "\n")
 	containerMap := make(map[string]string)
 
-	for _, containerID := range idList {
-		shortContainerID := utils.GetShortId(containerID)
-
-		cmdName := exec.Command("docker", "inspect", "--format={{.Name}}", containerID)
-		var nameOut bytes.Buffer
-		cmdName.Stdout = &nameOut
-		cmdName.Stderr = os.Stderr
-		if err := cmdName.Run(); err != nil {
-			return nil, err
-		}
-
-		name := strings.TrimSpace(nameOut.String())
-		// Strip leading '/' from container name
-		name = strings.TrimPrefix(name, "/")
-		containerMap[name] = containerID
+	for _, container := range containers {
+		shortContainerID := utils.GetShortId(container.ID)
+		name := container.Name()
+		containerMap[name] = container.ID
 		utils.Logger(utils.ColorGreen, "Container %s (%s) started.", name, shortContainerID)
 	}
 
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"docker-deployment/src/engine"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeContainer is a container known to the fake daemon.
+type fakeContainer struct {
+	ID      string
+	Name    string
+	Project string
+	Service string
+	Image   string
+	// Env is the environment the container runs with.
+	Env []string
+	// Health is the healthcheck status, empty for a container without healthcheck.
+	Health string
+}
+
+// fakeDaemon answers the Docker Engine API calls a deployment makes with a fixed set of containers.
+// Log and event streams stay open until the request is cancelled, like the real ones.
+type fakeDaemon struct {
+	mutex      sync.Mutex
+	containers []*fakeContainer
+	// images maps image ids to their repo digests
+	images   map[string][]string
+	requests []string
+	// pruneFilters are the filters of the prune calls received
+	pruneFilters []map[string][]string
+}
+
+// startFakeDaemon makes the default engine client talk to a fake daemon running containers.
+func startFakeDaemon(t *testing.T, containers ...*fakeContainer) *fakeDaemon {
+	t.Helper()
+
+	daemon := &fakeDaemon{containers: containers, images: map[string][]string{}}
+	server := httptest.NewServer(daemon)
+	t.Cleanup(server.Close)
+
+	client, err := engine.New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetDefault(client)
+	t.Cleanup(func() { engine.SetDefault(nil) })
+	return daemon
+}
+
+// Requests returns the method and path of the calls received so far.
+func (d *fakeDaemon) Requests() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]string(nil), d.requests...)
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+engine.APIVersion)
+
+	d.mutex.Lock()
+	d.requests = append(d.requests, r.Method+" "+path)
+	d.mutex.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/prune"):
+		var filters map[string][]string
+		_ = json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+		d.mutex.Lock()
+		d.pruneFilters = append(d.pruneFilters, filters)
+		d.mutex.Unlock()
+		writeJSON(w, map[string]any{})
+	case r.Method == http.MethodPost && (strings.HasSuffix(path, "/start") || strings.HasSuffix(path, "/stop")):
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/networks" || path == "/images/json":
+		writeJSON(w, []any{})
+	case path == "/containers/json":
+		d.list(w, r)
+	case strings.HasPrefix(path, "/containers/") && strings.HasSuffix(path, "/json"):
+		d.inspect(w, strings.TrimSuffix(strings.TrimPrefix(path, "/containers/"), "/json"))
+	case strings.HasPrefix(path, "/containers/") && strings.HasSuffix(path, "/logs"):
+		if r.URL.Query().Get("follow") == "1" {
+			stream(w, r)
+		}
+	case strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/json"):
+		d.inspectImage(w, strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json"))
+	case path == "/events":
+		stream(w, r)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]string{"message": "no such object: " + path})
+	}
+}
+
+func (d *fakeDaemon) list(w http.ResponseWriter, r *http.Request) {
+	var filters map[string][]string
+	_ = json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	containers := []engine.Container{}
+	for _, container := range d.containers {
+		labels := container.labels()
+		matches := true
+		for _, label := range filters["label"] {
+			key, value, _ := strings.Cut(label, "=")
+			matches = matches && labels[key] == value
+		}
+		if matches {
+			containers = append(containers, engine.Container{
+				ID: container.ID, Names: []string{"/" + container.Name}, Image: container.Image, Labels: labels, State: "running",
+			})
+		}
+	}
+	writeJSON(w, containers)
+}
+
+func (d *fakeDaemon) inspect(w http.ResponseWriter, nameOrID string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, container := range d.containers {
+		if container.ID != nameOrID && container.Name != nameOrID {
+			continue
+		}
+		inspect := engine.ContainerJSON{
+			ID:     container.ID,
+			Name:   "/" + container.Name,
+			Image:  "sha256:" + container.ID,
+			State:  engine.ContainerState{Status: "running", Running: true},
+			Config: engine.ContainerConfig{Image: container.Image, Env: container.Env, Labels: container.labels()},
+		}
+		if container.Health != "" {
+			inspect.State.Health = &engine.Health{Status: container.Health}
+		}
+		writeJSON(w, inspect)
+		return
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	writeJSON(w, map[string]string{"message": "No such container: " + nameOrID})
+}
+
+func (d *fakeDaemon) inspectImage(w http.ResponseWriter, id string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	repoDigests, ok := d.images[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]string{"message": "No such image: " + id})
+		return
+	}
+	writeJSON(w, engine.Image{ID: id, RepoDigests: repoDigests})
+}
+
+func (c *fakeContainer) labels() map[string]string {
+	return map[string]string{engine.ComposeProjectLabel: c.Project, engine.ComposeServiceLabel: c.Service}
+}
+
+// stream sends the headers of a streaming response and holds it open until the client goes away.
+func stream(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	<-r.Context().Done()
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"docker-deployment/src/validation"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"sort"
+)
+
+// Conditions of the long depends_on syntax.
+const (
+	ConditionStarted   = validation.ConditionStarted
+	ConditionHealthy   = validation.ConditionHealthy
+	ConditionCompleted = validation.ConditionCompleted
+)
+
+// Dependency is an entry of depends_on. Condition is empty for the short syntax.
+type Dependency struct {
+	Condition string `yaml:"condition,omitempty"`
+	Restart   bool   `yaml:"restart,omitempty"`
+	Required  *bool  `yaml:"required,omitempty"`
+}
+
+// required reports whether the dependency must be met. Compose only warns about a dependency with
+// required: false that is not available, so it is not waited for.
+func (d Dependency) required() bool {
+	return d.Required == nil || *d.Required
+}
+
+// Dependencies is depends_on, written either as a list of services or as a mapping of services to
+// their condition.
+type Dependencies map[string]Dependency
+
+// UnmarshalYAML decodes both the short and the long depends_on syntax.
+func (d *Dependencies) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		var names []string
+		if err := node.Decode(&names); err != nil {
+			return err
+		}
+		*d = make(Dependencies, len(names))
+		for _, name := range names {
+			(*d)[name] = Dependency{}
+		}
+		return nil
+	case yaml.MappingNode:
+		var dependencies map[string]Dependency
+		if err := node.Decode(&dependencies); err != nil {
+			return err
+		}
+		for name, dependency := range dependencies {
+			switch dependency.Condition {
+			case "":
+				dependency.Condition = ConditionStarted
+			case ConditionStarted, ConditionHealthy, ConditionCompleted:
+			default:
+				return fmt.Errorf("line %d: unknown depends_on condition %q of %s", node.Line, dependency.Condition, name)
+			}
+			dependencies[name] = dependency
+		}
+		*d = dependencies
+		return nil
+	default:
+		return fmt.Errorf("line %d: depends_on must be a list or a mapping", node.Line)
+	}
+}
+
+// names returns the services depended on, in alphabetical order.
+func (d Dependencies) names() []string {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// conditions maps the required services depended on to the condition they must meet.
+func (d Dependencies) conditions() map[string]string {
+	conditions := make(map[string]string, len(d))
+	for name, dependency := range d {
+		if dependency.required() {
+			conditions[name] = dependency.Condition
+		}
+	}
+	return conditions
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDependenciesUnmarshalYAML(t *testing.T) {
+	required := false
+
+	tests := []struct {
+		name    string
+		content string
+		want    Dependencies
+		err     string
+	}{
+		{
+			name:    "short syntax",
+			content: `[db, cache]`,
+			want:    Dependencies{"db": {}, "cache": {}},
+		},
+		{
+			name: "long syntax",
+			content: `
+db: {condition: service_healthy, restart: true}
+migrate: {condition: service_completed_successfully}
+cache: {required: false}`,
+			want: Dependencies{
+				"db":      {Condition: ConditionHealthy, Restart: true},
+				"migrate": {Condition: ConditionCompleted},
+				"cache":   {Condition: ConditionStarted, Required: &required},
+			},
+		},
+		{
+			name:    "unknown condition",
+			content: `{db: {condition: service_ready}}`,
+			err:     `unknown depends_on condition "service_ready" of db`,
+		},
+		{
+			name:    "scalar",
+			content: `db`,
+			err:     "depends_on must be a list or a mapping",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var dependencies Dependencies
+			err := yaml.Unmarshal([]byte(test.content), &dependencies)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(dependencies, test.want) {
+				t.Errorf("dependencies = %+v, want %+v", dependencies, test.want)
+			}
+		})
+	}
+}
+
+func TestDependenciesConditions(t *testing.T) {
+	required, optional := true, false
+	dependencies := Dependencies{
+		"db":     {Condition: ConditionHealthy},
+		"api":    {Condition: ConditionStarted, Required: &required},
+		"cache":  {Condition: ConditionStarted, Required: &optional},
+		"report": {Condition: ConditionCompleted, Required: &optional},
+	}
+
+	want := map[string]string{"db": ConditionHealthy, "api": ConditionStarted}
+	if got := dependencies.conditions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("conditions() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"docker-deployment/src/validation"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeploymentExtension is the x-deployment extension of a service, holding the settings of this tool.
+type DeploymentExtension struct {
+	Probes []ProbeConfig `yaml:"probes,omitempty"`
+	// Job marks a one-shot service, like a migration or a seed, that must exit 0
+	Job bool `yaml:"job,omitempty"`
+}
+
+// ProbeConfig is a readiness probe declared in x-deployment. Exactly one of HTTP, TCP and Exec is set.
+type ProbeConfig struct {
+	HTTP     *HTTPProbeConfig `yaml:"http,omitempty"`
+	TCP      *TCPProbeConfig  `yaml:"tcp,omitempty"`
+	Exec     *ExecProbeConfig `yaml:"exec,omitempty"`
+	Timeout  string           `yaml:"timeout,omitempty"`
+	Interval string           `yaml:"interval,omitempty"`
+}
+
+// HTTPProbeConfig is a GET request that must answer with the expected status and body.
+type HTTPProbeConfig struct {
+	URL    string `yaml:"url,omitempty"`
+	Scheme string `yaml:"scheme,omitempty"`
+	Host   string `yaml:"host,omitempty"`
+	Port   int    `yaml:"port,omitempty"`
+	Path   string `yaml:"path,omitempty"`
+	Status int    `yaml:"status,omitempty"`
+	// Body is a regular expression the response body must match
+	Body string `yaml:"body,omitempty"`
+}
+
+// TCPProbeConfig is a TCP connection that must be accepted.
+type TCPProbeConfig struct {
+	Address string `yaml:"address,omitempty"`
+	Host    string `yaml:"host,omitempty"`
+	Port    int    `yaml:"port,omitempty"`
+}
+
+// ExecProbeConfig is a command run in the container that must exit 0.
+type ExecProbeConfig struct {
+	Command []string `yaml:"command"`
+}
+
+// probe converts the probe declared in the compose file to a validation probe.
+func (p ProbeConfig) probe() (validation.Probe, error) {
+	var probe validation.Probe
+
+	kinds := 0
+	if p.HTTP != nil {
+		kinds++
+		probe.Kind = validation.ProbeHTTP
+		probe.URL, probe.Scheme, probe.Host, probe.Port, probe.Path = p.HTTP.URL, p.HTTP.Scheme, p.HTTP.Host, p.HTTP.Port, p.HTTP.Path
+		probe.Status = p.HTTP.Status
+		if p.HTTP.URL == "" && p.HTTP.Port == 0 {
+			return probe, fmt.Errorf("http probe needs a url or a port")
+		}
+		if p.HTTP.Body != "" {
+			body, err := regexp.Compile(p.HTTP.Body)
+			if err != nil {
+				return probe, fmt.Errorf("invalid body expression %q: %w", p.HTTP.Body, err)
+			}
+			probe.Body = body
+		}
+	}
+	if p.TCP != nil {
+		kinds++
+		probe.Kind = validation.ProbeTCP
+		probe.Address, probe.Host, probe.Port = p.TCP.Address, p.TCP.Host, p.TCP.Port
+		if p.TCP.Address == "" && p.TCP.Port == 0 {
+			return probe, fmt.Errorf("tcp probe needs an address or a port")
+		}
+	}
+	if p.Exec != nil {
+		kinds++
+		probe.Kind = validation.ProbeExec
+		probe.Command = p.Exec.Command
+		if len(p.Exec.Command) == 0 {
+			return probe, fmt.Errorf("exec probe needs a command")
+		}
+	}
+	if kinds != 1 {
+		return probe, fmt.Errorf("a probe needs exactly one of http, tcp or exec")
+	}
+
+	var err error
+	if probe.Timeout, err = parseOptionalDuration(p.Timeout); err != nil {
+		return probe, fmt.Errorf("invalid probe timeout: %w", err)
+	}
+	if probe.Interval, err = parseOptionalDuration(p.Interval); err != nil {
+		return probe, fmt.Errorf("invalid probe interval: %w", err)
+	}
+	return probe, nil
+}
+
+// probes returns the readiness probes of the service. A probe on a container port reaches it through the
+// host port it is published on, so the port must be in the ports of the service.
+func (s Service) probes(name string) ([]validation.Probe, error) {
+	if s.Deployment == nil {
+		return nil, nil
+	}
+
+	var probes []validation.Probe
+	for i, config := range s.Deployment.Probes {
+		probe, err := config.probe()
+		if err != nil {
+			return nil, fmt.Errorf("probe %d of service %s: %w", i+1, name, err)
+		}
+		if probe.Port != 0 && probe.URL == "" && probe.Address == "" && !s.publishes(probe.Port) {
+			return nil, fmt.Errorf("probe %d of service %s: port %d is not in the ports of the service, "+
+				"publish it or give the probe a url or an address", i+1, name, probe.Port)
+		}
+		probes = append(probes, probe)
+	}
+	return probes, nil
+}
+
+// publishes reports whether the container port is in the TCP ports of the service.
+func (s Service) publishes(port int) bool {
+	for _, published := range s.Ports {
+		if published.Protocol != "" && published.Protocol != "tcp" {
+			continue
+		}
+		first, last, isRange := strings.Cut(published.Target, "-")
+		if !isRange {
+			last = first
+		}
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			continue
+		}
+		to, err := strconv.Atoi(last)
+		if err == nil && from <= port && port <= to {
+			return true
+		}
+	}
+	return false
+}
+
+// isJob reports whether the service is a one-shot job, either flagged in x-deployment or never restarted.
+func (s Service) isJob() bool {
+	return (s.Deployment != nil && s.Deployment.Job) || s.Restart == "no"
+}
+
+func parseOptionalDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// ProjectExtension is the top-level x-deployment extension of the compose file.
+type ProjectExtension struct {
+	SmokeTests []SmokeTestConfig `yaml:"smoke_tests,omitempty"`
+	Migration  *MigrationConfig  `yaml:"migration,omitempty"`
+}
+
+// MigrationConfig is the migration run with docker-compose run before the containers are started:
+// the service itself, or Command in a new container of the service.
+type MigrationConfig struct {
+	Service string   `yaml:"service"`
+	Command []string `yaml:"command,omitempty"`
+	Timeout string   `yaml:"timeout,omitempty"`
+}
+
+// SmokeTestConfig is a smoke test run after every container is healthy. Exactly one of HTTP and
+// Container is set.
+type SmokeTestConfig struct {
+	Name      string               `yaml:"name"`
+	HTTP      *HTTPTestConfig      `yaml:"http,omitempty"`
+	Container *ContainerTestConfig `yaml:"container,omitempty"`
+	Timeout   string               `yaml:"timeout,omitempty"`
+	Retries   int                  `yaml:"retries,omitempty"`
+	Interval  string               `yaml:"interval,omitempty"`
+}
+
+// HTTPTestConfig is a request and the response it expects.
+type HTTPTestConfig struct {
+	Method  string            `yaml:"method,omitempty"`
+	URL     string            `yaml:"url,omitempty"`
+	Service string            `yaml:"service,omitempty"`
+	Port    int               `yaml:"port,omitempty"`
+	Path    string            `yaml:"path,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+	Expect  struct {
+		Status int `yaml:"status,omitempty"`
+		// Headers and Body are regular expressions the response must match
+		Headers map[string]string `yaml:"headers,omitempty"`
+		Body    string            `yaml:"body,omitempty"`
+	} `yaml:"expect,omitempty"`
+}
+
+// ContainerTestConfig is a one-off container run on the project network that must exit 0.
+type ContainerTestConfig struct {
+	Image       string            `yaml:"image"`
+	Command     []string          `yaml:"command,omitempty"`
+	Environment map[string]string `yaml:"environment,omitempty"`
+	Network     string            `yaml:"network,omitempty"`
+}
+
+// smokeTest converts the smoke test declared in the compose file to a validation smoke test.
+func (t SmokeTestConfig) smokeTest(services *Services) (validation.SmokeTest, error) {
+	test := validation.SmokeTest{Name: t.Name, Retries: t.Retries}
+	if t.Name == "" {
+		return test, fmt.Errorf("smoke test needs a name")
+	}
+	if (t.HTTP == nil) == (t.Container == nil) {
+		return test, fmt.Errorf("smoke test %q needs exactly one of http or container", t.Name)
+	}
+
+	var err error
+	if test.Timeout, err = parseOptionalDuration(t.Timeout); err != nil {
+		return test, fmt.Errorf("invalid timeout of smoke test %q: %w", t.Name, err)
+	}
+	if test.Interval, err = parseOptionalDuration(t.Interval); err != nil {
+		return test, fmt.Errorf("invalid interval of smoke test %q: %w", t.Name, err)
+	}
+
+	if t.Container != nil {
+		if t.Container.Image == "" {
+			return test, fmt.Errorf("smoke test %q needs an image", t.Name)
+		}
+		test.Container = &validation.TestContainer{
+			Image:   t.Container.Image,
+			Command: t.Container.Command,
+			Network: t.Container.Network,
+		}
+		for _, key := range sortedKeys(t.Container.Environment) {
+			test.Container.Env = append(test.Container.Env, key+"="+t.Container.Environment[key])
+		}
+		return test, nil
+	}
+
+	request := &validation.HTTPRequest{
+		Method:          t.HTTP.Method,
+		URL:             t.HTTP.URL,
+		Service:         t.HTTP.Service,
+		Port:            t.HTTP.Port,
+		Path:            t.HTTP.Path,
+		Headers:         t.HTTP.Headers,
+		Body:            t.HTTP.Body,
+		Status:          t.HTTP.Expect.Status,
+		ResponseHeaders: map[string]*regexp.Regexp{},
+	}
+	if request.URL == "" {
+		if _, ok := services.Services[request.Service]; !ok || request.Port == 0 {
+			return test, fmt.Errorf("smoke test %q needs a url, or the service and port to request", t.Name)
+		}
+	}
+	for header, expression := range t.HTTP.Expect.Headers {
+		if request.ResponseHeaders[header], err = regexp.Compile(expression); err != nil {
+			return test, fmt.Errorf("invalid header expression of smoke test %q: %w", t.Name, err)
+		}
+	}
+	if t.HTTP.Expect.Body != "" {
+		if request.ResponseBody, err = regexp.Compile(t.HTTP.Expect.Body); err != nil {
+			return test, fmt.Errorf("invalid body expression of smoke test %q: %w", t.Name, err)
+		}
+	}
+	test.HTTP = request
+	return test, nil
+}
+
+// smokeTests returns the smoke tests declared in the compose file.
+func (s *Services) smokeTests() ([]validation.SmokeTest, error) {
+	if s.Deployment == nil {
+		return nil, nil
+	}
+
+	tests := make([]validation.SmokeTest, 0, len(s.Deployment.SmokeTests))
+	for _, config := range s.Deployment.SmokeTests {
+		test, err := config.smokeTest(s)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, test)
+	}
+	return tests, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServiceProbes(t *testing.T) {
+	tests := []struct {
+		name    string
+		compose string
+		want    []string
+		err     string
+	}{
+		{
+			name: "published port",
+			compose: `services:
+  web:
+    ports: ["8080:80"]
+    x-deployment:
+      probes: [{http: {port: 80, path: /health}}]`,
+			want: []string{"http :80/health"},
+		},
+		{
+			name: "port of a range without host port",
+			compose: `services:
+  web:
+    ports: [{target: 9000-9010}]
+    x-deployment:
+      probes: [{tcp: {port: 9005}}]`,
+			want: []string{"tcp :9005"},
+		},
+		{
+			name: "url and address need no published port",
+			compose: `services:
+  web:
+    x-deployment:
+      probes: [{http: {url: "http://web.internal/health"}}, {tcp: {address: "db:5432"}}, {exec: {command: [true]}}]`,
+			want: []string{"http http://web.internal/health", "tcp db:5432", "exec true"},
+		},
+		{
+			name: "port not published",
+			compose: `services:
+  web:
+    x-deployment:
+      probes: [{http: {port: 80}}]`,
+			err: "probe 1 of service web: port 80 is not in the ports of the service",
+		},
+		{
+			name: "other port published",
+			compose: `services:
+  web:
+    ports: ["8080:80", "53:53/udp"]
+    x-deployment:
+      probes: [{tcp: {port: 53}}]`,
+			err: "port 53 is not in the ports of the service",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			services := parseServices(t, test.compose)
+
+			probes, err := services.Services["web"].probes("web")
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, probe := range probes {
+				got = append(got, probe.String())
+			}
+			if strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+				t.Errorf("probes = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
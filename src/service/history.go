@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/history"
+	"docker-deployment/src/runner"
+	"docker-deployment/src/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// deploymentRecord tracks the running deployment so its outcome ends up in the history ledger.
+type deploymentRecord struct {
+	volume  string
+	entry   history.Entry
+	compose []byte
+	started time.Time
+}
+
+var activeRecord *deploymentRecord
+
+// beginRecord starts tracking a deployment run with config.
+func beginRecord(config Config) {
+	activeRecord = &deploymentRecord{
+		volume:  config.HistoryVolume,
+		started: time.Now(),
+		entry: history.Entry{
+			ComposeFile: config.ComposeFile,
+			Strategy:    config.Strategy,
+			RollbackOf:  config.rollbackOf,
+			Images:      map[string]string{},
+		},
+	}
+}
+
+// attachDeployment records which deployment is running and the compose file it deploys.
+func attachDeployment(deploymentID string, composePath string) {
+	if activeRecord == nil {
+		return
+	}
+	activeRecord.entry.ID = deploymentID
+	if content, err := os.ReadFile(composePath); err == nil {
+		activeRecord.compose = content
+		activeRecord.entry.ComposeHash = history.HashCompose(content)
+	}
+}
+
+// recordImages resolves the images the containers run to their digests.
+func recordImages(containerMap map[string]string) {
+	if activeRecord == nil {
+		return
+	}
+	for _, containerID := range containerMap {
+		container, err := inspectContainer(containerID)
+		if err != nil || container == nil {
+			continue
+		}
+		serviceName := container.Config.Labels[engine.ComposeServiceLabel]
+		if serviceName == "" {
+			serviceName = container.ShortName()
+		}
+		activeRecord.entry.Images[serviceName] = pinnedReference(container.Config.Image, container.Image)
+	}
+}
+
+// recordPinnedImages records the digests the images of the services were pinned to.
+func recordPinnedImages(images map[string]string) {
+	if activeRecord == nil {
+		return
+	}
+	for serviceName, image := range images {
+		activeRecord.entry.Images[serviceName] = image
+	}
+}
+
+// recordMigration records the migration run by the deployment.
+func recordMigration(migration history.Migration) {
+	if activeRecord == nil {
+		return
+	}
+	activeRecord.entry.Migration = &migration
+}
+
+// pinnedReference returns reference pinned to the digest of imageID, or imageID itself when the image
+// has no repo digest (e.g. it was built locally).
+func pinnedReference(reference string, imageID string) string {
+	repository := reference
+	if at := strings.Index(repository, "@"); at >= 0 {
+		repository = repository[:at]
+	} else if colon := strings.LastIndex(repository, ":"); colon > strings.LastIndex(repository, "/") {
+		repository = repository[:colon]
+	}
+
+	digests := imageRepoDigests(imageID)
+	for _, repoDigest := range digests {
+		if strings.HasPrefix(repoDigest, repository+"@") {
+			return repoDigest
+		}
+	}
+	if digest := firstDigest(digests); digest != "" {
+		return repository + "@" + digest
+	}
+	return imageID
+}
+
+// finishRecord writes the deployment outcome to the history ledger.
+func finishRecord(outcome string, message string) {
+	// The deployment is over, the registries it logged in to are not needed anymore
+	logoutRegistries()
+
+	record := activeRecord
+	activeRecord = nil
+	if record == nil || record.entry.ID == "" {
+		return
+	}
+
+	record.entry.Outcome = outcome
+	record.entry.Message = message
+	record.entry.Timestamp = record.started.UTC()
+	record.entry.Duration = time.Since(record.started).Round(time.Millisecond).Seconds()
+
+	if runner.IsDryRun() {
+		utils.Logger(utils.ColorYellow, "[dry-run] record deployment %s as %s", record.entry.ID, outcome)
+		return
+	}
+
+	ledger, err := openLedger(record.volume)
+	if err == nil {
+		err = ledger.Record(context.Background(), record.entry, record.compose)
+	}
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error recording deployment %s in the history: %s", record.entry.ID, err)
+		return
+	}
+
+	utils.Logger(utils.ColorBlue, "Deployment %s recorded as %s (%.1fs)", record.entry.ID, outcome, record.entry.Duration)
+}
+
+// deploymentError is the error of a deployment that did not succeed, with the outcome it was recorded as.
+type deploymentError struct {
+	Outcome string
+	Message string
+}
+
+func (e *deploymentError) Error() string {
+	return e.Message
+}
+
+// exitDeployment records the deployment that did not succeed and returns its error.
+func exitDeployment(outcome string, message string) error {
+	finishRecord(outcome, message)
+	return &deploymentError{Outcome: outcome, Message: message}
+}
+
+func openLedger(volume string) (*history.Ledger, error) {
+	client, err := engine.Default()
+	if err != nil {
+		return nil, err
+	}
+	return history.Open(context.Background(), client, volume)
+}
+
+// RollbackTo redeploys a previous successful deployment from the history ledger and validates it
+// like any other deployment. Without id, the successful deployment before the current one is used.
+func RollbackTo(config Config, id string) error {
+	ctx := context.Background()
+
+	ledger, err := openLedger(config.HistoryVolume)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error opening deployment history: %s", err)
+		return err
+	}
+
+	entries, err := ledger.Entries(ctx)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error reading deployment history: %s", err)
+		return err
+	}
+
+	target, err := rollbackTarget(entries, id)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error: %s", err)
+		return err
+	}
+
+	content, err := ledger.Compose(ctx, target.ID)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error reading compose file of %s: %s", target.ID, err)
+		return err
+	}
+
+	composePath, err := writeRollbackCompose(target, content)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error writing compose file of %s: %s", target.ID, err)
+		return err
+	}
+
+	utils.Logger(utils.ColorYellow, "Rolling back to deployment %s from %s",
+		target.ID, target.Timestamp.Local().Format("2006/01/02 15:04:05"))
+
+	config.ComposeFile = composePath
+	config.rollbackOf = target.ID
+	if config.Strategy == "" {
+		config.Strategy = target.Strategy
+	}
+
+	return Start(config)
+}
+
+// rollbackTarget returns the successful entry with id, or the successful deployment made before the running
+// version.
+func rollbackTarget(entries []history.Entry, id string) (*history.Entry, error) {
+	var successful []history.Entry
+	for _, entry := range entries {
+		if entry.Outcome == history.OutcomeSuccess {
+			successful = append(successful, entry)
+		}
+	}
+
+	if id != "" {
+		for i := range entries {
+			if entries[i].ID != id {
+				continue
+			}
+			if entries[i].Outcome != history.OutcomeSuccess {
+				return nil, fmt.Errorf("deployment %s did not succeed (%s)", id, entries[i].Outcome)
+			}
+			return &entries[i], nil
+		}
+		return nil, fmt.Errorf("deployment %s not found in the history", id)
+	}
+
+	if len(successful) == 0 {
+		return nil, fmt.Errorf("no previous successful deployment in the history")
+	}
+
+	// A rollback redeploys the entry it rolled back to, so the running version is that entry and the
+	// target is the deployment made before it, never one that was itself a rollback.
+	byID := map[string]history.Entry{}
+	for _, entry := range successful {
+		byID[entry.ID] = entry
+	}
+	current := successful[len(successful)-1]
+	for seen := map[string]bool{}; current.RollbackOf != "" && !seen[current.ID]; {
+		seen[current.ID] = true
+		origin, ok := byID[current.RollbackOf]
+		if !ok {
+			break
+		}
+		current = origin
+	}
+
+	for i := len(successful) - 1; i >= 0; i-- {
+		if successful[i].ID != current.ID {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if successful[j].RollbackOf == "" {
+				return &successful[j], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no previous successful deployment in the history")
+}
+
+// writeRollbackCompose writes the compose file of entry, pinned to the images it deployed.
+func writeRollbackCompose(entry *history.Entry, content []byte) (string, error) {
+	dir := filepath.Join("_temp", "rollback", entry.ID)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	source := filepath.Join(dir, "recorded.yaml")
+	if err := os.WriteFile(source, content, 0644); err != nil {
+		return "", err
+	}
+
+	images := map[string]string{}
+	for serviceName, image := range entry.Images {
+		if hasService(source, serviceName) {
+			images[serviceName] = image
+		}
+	}
+
+	destination := filepath.Join(dir, "docker-compose.yaml")
+	return destination, writePinnedCompose(source, destination, images)
+}
+
+// PrintHistory prints the deployments recorded in the history ledger.
+func PrintHistory(config Config) {
+	ledger, err := openLedger(config.HistoryVolume)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error opening deployment history: %s", err)
+		os.Exit(1)
+	}
+
+	entries, err := ledger.Entries(context.Background())
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error reading deployment history: %s", err)
+		os.Exit(1)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No deployments recorded.")
+		return
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		fmt.Printf("%s  %s  %-11s %7.1fs  %s\n", entry.ID, entry.Timestamp.Local().Format("2006/01/02 15:04:05"),
+			entry.Outcome, entry.Duration, entry.ComposeFile)
+		if entry.RollbackOf != "" {
+			fmt.Printf("    rollback of %s\n", entry.RollbackOf)
+		}
+		if entry.Message != "" {
+			fmt.Printf("    %s\n", entry.Message)
+		}
+		if migration := entry.Migration; migration != nil {
+			fmt.Printf("    migration %s exited %d (%.1fs)\n", migration.Service, migration.ExitCode, migration.Duration)
+		}
+		for _, serviceName := range sortedKeys(entry.Images) {
+			fmt.Printf("    %s: %s\n", serviceName, entry.Images[serviceName])
+		}
+	}
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"docker-deployment/src/history"
+	"testing"
+)
+
+func TestPinnedReference(t *testing.T) {
+	daemon := startFakeDaemon(t)
+	daemon.images["sha256:nginx"] = []string{"nginx@sha256:1111"}
+	daemon.images["sha256:api"] = []string{"ghcr.io/acme/api@sha256:2222", "registry.example.com:5000/api@sha256:3333"}
+	daemon.images["sha256:local"] = nil
+
+	tests := []struct {
+		reference string
+		imageID   string
+		want      string
+	}{
+		{"nginx:1.27", "sha256:nginx", "nginx@sha256:1111"},
+		{"nginx", "sha256:nginx", "nginx@sha256:1111"},
+		{"nginx@sha256:0000", "sha256:nginx", "nginx@sha256:1111"},
+		{"docker.io/library/nginx:1.27", "sha256:nginx", "docker.io/library/nginx@sha256:1111"},
+		{"registry.example.com:5000/api:2.1", "sha256:api", "registry.example.com:5000/api@sha256:3333"},
+		{"registry.example.com:5000/api", "sha256:api", "registry.example.com:5000/api@sha256:3333"},
+		{"ghcr.io/acme/api:2.1", "sha256:api", "ghcr.io/acme/api@sha256:2222"},
+		{"api:dev", "sha256:local", "sha256:local"},
+		{"api:dev", "sha256:missing", "sha256:missing"},
+	}
+
+	for _, test := range tests {
+		if got := pinnedReference(test.reference, test.imageID); got != test.want {
+			t.Errorf("pinnedReference(%q, %q) = %q, want %q", test.reference, test.imageID, got, test.want)
+		}
+	}
+}
+
+func TestRollbackTarget(t *testing.T) {
+	success := func(id string, rollbackOf string) history.Entry {
+		return history.Entry{ID: id, Outcome: history.OutcomeSuccess, RollbackOf: rollbackOf}
+	}
+	failed := history.Entry{ID: "f", Outcome: history.OutcomeFailed}
+
+	tests := []struct {
+		name    string
+		entries []history.Entry
+		id      string
+		want    string
+	}{
+		{"previous deployment", []history.Entry{success("a", ""), success("b", "")}, "", "a"},
+		{"failed deployments skipped", []history.Entry{success("a", ""), success("b", ""), failed}, "", "a"},
+		{"after a rollback", []history.Entry{success("a", ""), success("b", ""), success("c", ""), success("r1", "b")}, "", "a"},
+		{"after two rollbacks", []history.Entry{success("a", ""), success("b", ""), success("c", ""), success("r1", "b"), success("r2", "a")}, "", ""},
+		{"rollback of a rollback", []history.Entry{success("a", ""), success("b", ""), success("c", ""), success("r1", "b"), success("r2", "r1")}, "", "a"},
+		{"rollbacks between deployments", []history.Entry{success("a", ""), success("r1", "a"), success("b", "")}, "", "a"},
+		{"explicit id", []history.Entry{success("a", ""), success("b", ""), success("c", "")}, "b", "b"},
+		{"explicit failed id", []history.Entry{success("a", ""), failed}, "f", ""},
+		{"unknown id", []history.Entry{success("a", "")}, "x", ""},
+		{"single deployment", []history.Entry{success("a", "")}, "", ""},
+		{"empty history", nil, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target, err := rollbackTarget(test.entries, test.id)
+			if test.want == "" {
+				if err == nil {
+					t.Fatalf("rollbackTarget() = %s, want an error", target.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("rollbackTarget() = %v", err)
+			}
+			if target.ID != test.want {
+				t.Errorf("rollbackTarget() = %s, want %s", target.ID, test.want)
+			}
+		})
+	}
+}
@@ -2,18 +2,388 @@ package service
 
 import (
 	"context"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/runner"
 	"docker-deployment/src/utils"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
 )
 
-func DockerLogin(dockerHost string, dockerUsername string, dockerPassword string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
-	defer cancel()
+// dockerHub is the registry of the images without registry host.
+const dockerHub = "docker.io"
 
-	err := utils.RunCommand(ctx, "docker", "login", dockerHost, "-p", dockerPassword, "-u", dockerUsername)
+// loginTimeout bounds the check of the credentials of a registry.
+const loginTimeout = 2 * time.Minute
+
+// RegistryCredential is the account used to log in to a registry.
+type RegistryCredential struct {
+	Registry string `yaml:"registry"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Source tells where the credential comes from, e.g. "environment".
+	Source string `yaml:"-"`
+}
+
+// registryCredentials are the credentials available to the running deployment, by registry.
+var registryCredentials map[string]RegistryCredential
+
+// runConfig is the docker config directory holding the credentials of the running deployment. It is
+// handed to compose through DOCKER_CONFIG, so the config of the user is never written to.
+type runConfig struct {
+	dir      string
+	previous string
+	wasSet   bool
+	signals  chan os.Signal
+}
+
+// activeConfig is the docker config directory of the running deployment, nil when it logged in nowhere.
+var activeConfig *runConfig
+
+// registryCredentials collects the credentials from the environment, the credentials file and the
+// docker config file of the configuration. The environment wins over the files.
+func (c Config) registryCredentials() (map[string]RegistryCredential, error) {
+	credentials := map[string]RegistryCredential{}
+	add := func(credential RegistryCredential) {
+		credential.Registry = normalizeRegistry(credential.Registry)
+		credentials[credential.Registry] = credential
+	}
+
+	if c.RegistryDockerConfig != "" {
+		fromConfig, err := readDockerConfigCredentials(c.RegistryDockerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error reading docker config %s: %w", c.RegistryDockerConfig, err)
+		}
+		for _, credential := range fromConfig {
+			add(credential)
+		}
+	}
+
+	if c.RegistryCredentialsFile != "" {
+		fromFile, err := readCredentialsFile(c.RegistryCredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading registry credentials %s: %w", c.RegistryCredentialsFile, err)
+		}
+		for _, credential := range fromFile {
+			add(credential)
+		}
+	}
+
+	if c.RegistryUsername != "" || c.RegistryPassword != "" {
+		if c.RegistryHost == "" || c.RegistryUsername == "" || c.RegistryPassword == "" {
+			return nil, fmt.Errorf("DOCKER_REGISTRY_HOST, DOCKER_REGISTRY_USERNAME and DOCKER_REGISTRY_PASSWORD must be set together")
+		}
+		add(RegistryCredential{
+			Registry: c.RegistryHost,
+			Username: c.RegistryUsername,
+			Password: c.RegistryPassword,
+			Source:   "environment",
+		})
+	}
+	return credentials, nil
+}
+
+// readCredentialsFile reads a YAML or JSON file holding a list of registries and their credentials.
+func readCredentialsFile(path string) ([]RegistryCredential, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Registries []RegistryCredential `yaml:"registries"`
+	}
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+	for i := range file.Registries {
+		credential := &file.Registries[i]
+		if credential.Registry == "" || credential.Username == "" || credential.Password == "" {
+			return nil, fmt.Errorf("entry %d needs a registry, a username and a password", i+1)
+		}
+		credential.Source = path
+	}
+	return file.Registries, nil
+}
+
+// dockerConfigFile is the part of a docker config.json holding registry credentials.
+type dockerConfigFile struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+}
+
+// readDockerConfigCredentials reads the credentials stored in the auths of a docker config.json.
+// Entries kept by a credential helper hold no password and are left out.
+func readDockerConfigCredentials(path string) ([]RegistryCredential, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config dockerConfigFile
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+
+	var credentials []RegistryCredential
+	for _, registry := range sortedAuths(config) {
+		auth := config.Auths[registry]
+		username, password := auth.Username, auth.Password
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth of %s: %w", registry, err)
+			}
+			username, password, _ = strings.Cut(string(decoded), ":")
+		}
+		if username == "" || password == "" {
+			utils.Logger(utils.ColorYellow, "No password for %s in %s, skipping it", registry, path)
+			continue
+		}
+		credentials = append(credentials, RegistryCredential{Registry: registry, Username: username, Password: password, Source: path})
+	}
+	return credentials, nil
+}
+
+func sortedAuths(config dockerConfigFile) []string {
+	registries := make([]string, 0, len(config.Auths))
+	for registry := range config.Auths {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	return registries
+}
+
+// loginRegistries logs in to the registries of the images of the services that have credentials. The
+// credentials are checked against the registry through the Docker Engine, then written to a docker config
+// directory created for the run, which compose reads through DOCKER_CONFIG. The password never shows up in
+// a process list and the config of the user is left untouched. logoutRegistries removes the directory once
+// the deployment is over.
+func loginRegistries(services *Services, credentials map[string]RegistryCredential) error {
+	if len(credentials) == 0 {
+		return nil
+	}
+
+	client, err := engine.Default()
+	if err != nil {
+		return err
+	}
+
+	logins := map[string]RegistryCredential{}
+	for _, registry := range imageRegistries(services) {
+		credential, ok := credentials[registry]
+		if !ok {
+			continue
+		}
+
+		utils.Logger(utils.ColorBlue, "Logging in to %s as %s (%s)...", registry, credential.Username, credential.Source)
+		ctx, cancel := context.WithTimeout(context.Background(), loginTimeout)
+		_, err := client.Auth(ctx, engine.AuthConfig{
+			Username:      credential.Username,
+			Password:      credential.Password,
+			ServerAddress: registryAddress(registry),
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("login to %s failed: %w", registry, err)
+		}
+		logins[registryAddress(registry)] = credential
+		utils.Logger(utils.ColorGreen, "Logged in to %s", registry)
+	}
+
+	if len(logins) == 0 {
+		return nil
+	}
+	if runner.IsDryRun() {
+		utils.Logger(utils.ColorYellow, "[dry-run] write the credentials of %d registries to a docker config for the run", len(logins))
+		return nil
+	}
+	return useRunConfig(logins)
+}
+
+// runConfigLinks are the directories of the docker config directory the docker CLI reads besides
+// config.json: the plugins, like compose, and the contexts.
+var runConfigLinks = []string{"cli-plugins", "contexts"}
+
+// useRunConfig writes a copy of the docker config of the user with the credentials of logins to a new
+// directory and points DOCKER_CONFIG at it. The plugins and contexts of the user are linked into it, so
+// compose and the current context keep working. When the process is interrupted, the directory is
+// removed and the signal is raised again.
+func useRunConfig(logins map[string]RegistryCredential) error {
+	configPath := dockerConfigPath()
+	config, err := runDockerConfig(configPath, logins)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "docker-deployment-config-")
 	if err != nil {
-		utils.Logger(utils.ColorRed, "Error: Docker login failed.", err)
-		os.Exit(1)
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), config, 0600); err != nil {
+		_ = os.RemoveAll(dir)
+		return err
+	}
+	if configPath != "" {
+		if err := linkConfigDirs(filepath.Dir(configPath), dir); err != nil {
+			_ = os.RemoveAll(dir)
+			return err
+		}
+	}
+
+	current := &runConfig{dir: dir, signals: make(chan os.Signal, 1)}
+	current.previous, current.wasSet = os.LookupEnv("DOCKER_CONFIG")
+	activeConfig = current
+
+	signal.Notify(current.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if sig, ok := <-current.signals; ok {
+			_ = os.RemoveAll(dir)
+			signal.Stop(current.signals)
+			if process, err := os.FindProcess(os.Getpid()); err == nil {
+				_ = process.Signal(sig)
+			}
+		}
+	}()
+
+	return os.Setenv("DOCKER_CONFIG", dir)
+}
+
+// linkConfigDirs links the runConfigLinks directories of the docker config directory source into target.
+func linkConfigDirs(source string, target string) error {
+	for _, name := range runConfigLinks {
+		path := filepath.Join(source, name)
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := os.Symlink(path, filepath.Join(target, name)); err != nil {
+			return fmt.Errorf("error linking %s into the docker config of the run: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// runDockerConfig returns the docker config at configPath with the credentials of logins stored in its auths.
+// Other settings, and the credential helpers of the other registries, are kept. An empty credential helper
+// makes compose read the registry from auths even when the config sets a credsStore.
+func runDockerConfig(configPath string, logins map[string]RegistryCredential) ([]byte, error) {
+	config := map[string]any{}
+	if content, err := os.ReadFile(configPath); err == nil {
+		if err := json.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf("error reading docker config %s: %w", configPath, err)
+		}
+	}
+
+	auths, _ := config["auths"].(map[string]any)
+	if auths == nil {
+		auths = map[string]any{}
+	}
+	helpers, _ := config["credHelpers"].(map[string]any)
+	if helpers == nil {
+		helpers = map[string]any{}
+	}
+	for address, credential := range logins {
+		auths[address] = map[string]string{
+			"auth": base64.StdEncoding.EncodeToString([]byte(credential.Username + ":" + credential.Password)),
+		}
+		helpers[address] = ""
+	}
+	config["auths"] = auths
+	config["credHelpers"] = helpers
+
+	return json.MarshalIndent(config, "", "\t")
+}
+
+// logoutRegistries removes the docker config directory loginRegistries created and restores DOCKER_CONFIG.
+func logoutRegistries() {
+	current := activeConfig
+	activeConfig = nil
+	if current == nil {
+		return
+	}
+
+	signal.Stop(current.signals)
+	close(current.signals)
+
+	if current.wasSet {
+		_ = os.Setenv("DOCKER_CONFIG", current.previous)
+	} else {
+		_ = os.Unsetenv("DOCKER_CONFIG")
+	}
+	if err := os.RemoveAll(current.dir); err != nil {
+		utils.Logger(utils.ColorYellow, "Error removing the registry credentials in %s: %s", current.dir, err)
+		return
+	}
+	utils.Logger(utils.ColorBlue, "Logged out of the registries")
+}
+
+// registryAddress returns the address the credentials of a registry are stored under, the legacy
+// index address for Docker Hub.
+func registryAddress(registry string) string {
+	if registry == dockerHub {
+		return "https://index.docker.io/v1/"
+	}
+	return registry
+}
+
+// imageRegistries returns the registries the images of the services started come from, in alphabetical
+// order.
+func imageRegistries(services *Services) []string {
+	seen := map[string]bool{}
+	var registries []string
+	for _, name := range services.activeNames() {
+		image := services.Services[name].Image
+		if image == "" {
+			continue
+		}
+		if registry := imageRegistry(image); !seen[registry] {
+			seen[registry] = true
+			registries = append(registries, registry)
+		}
+	}
+	sort.Strings(registries)
+	return registries
+}
+
+// imageRegistry returns the registry host of an image reference, docker.io when it names none.
+func imageRegistry(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if !found || !strings.ContainsAny(first, ".:") && first != "localhost" {
+		return dockerHub
+	}
+	return normalizeRegistry(first)
+}
+
+// normalizeRegistry returns the host of a registry address, with the Docker Hub aliases as docker.io.
+func normalizeRegistry(address string) string {
+	address = strings.TrimPrefix(strings.TrimPrefix(address, "https://"), "http://")
+	address, _, _ = strings.Cut(address, "/")
+	switch address {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return dockerHub
+	}
+	return address
+}
+
+// dockerConfigPath returns the config file of the docker CLI.
+func dockerConfigPath() string {
+	configDir := os.Getenv("DOCKER_CONFIG")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configDir = filepath.Join(home, ".docker")
 	}
+	return filepath.Join(configDir, "config.json")
 }
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImageRegistry(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"nginx", "docker.io"},
+		{"nginx:1.27", "docker.io"},
+		{"library/nginx:1.27", "docker.io"},
+		{"bitnami/redis@sha256:0123", "docker.io"},
+		{"docker.io/library/nginx", "docker.io"},
+		{"index.docker.io/library/nginx", "docker.io"},
+		{"ghcr.io/acme/api:2.1", "ghcr.io"},
+		{"registry.example.com:5000/api", "registry.example.com:5000"},
+		{"localhost/api", "localhost"},
+		{"localhost:5000/api:dev", "localhost:5000"},
+	}
+
+	for _, test := range tests {
+		if got := imageRegistry(test.image); got != test.want {
+			t.Errorf("imageRegistry(%q) = %q, want %q", test.image, got, test.want)
+		}
+	}
+}
+
+func TestNormalizeRegistry(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"docker.io", "docker.io"},
+		{"index.docker.io", "docker.io"},
+		{"https://index.docker.io/v1/", "docker.io"},
+		{"registry-1.docker.io", "docker.io"},
+		{"registry.hub.docker.com", "docker.io"},
+		{"https://ghcr.io", "ghcr.io"},
+		{"http://registry.example.com:5000/v2/", "registry.example.com:5000"},
+	}
+
+	for _, test := range tests {
+		if got := normalizeRegistry(test.address); got != test.want {
+			t.Errorf("normalizeRegistry(%q) = %q, want %q", test.address, got, test.want)
+		}
+	}
+}
+
+func TestRunDockerConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	user := `{"credsStore":"desktop","credHelpers":{"gcr.io":"gcloud"},"auths":{"quay.io":{"auth":"b2xkOm9sZA=="}},"proxies":{"default":{"httpProxy":"http://proxy:3128"}}}`
+	if err := os.WriteFile(configPath, []byte(user), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := runDockerConfig(configPath, map[string]RegistryCredential{
+		"ghcr.io":                     {Username: "deploy", Password: "s3cr3t"},
+		"https://index.docker.io/v1/": {Username: "hub", Password: "token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		CredsStore  string                       `json:"credsStore"`
+		CredHelpers map[string]string            `json:"credHelpers"`
+		Auths       map[string]map[string]string `json:"auths"`
+		Proxies     map[string]any               `json:"proxies"`
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.CredsStore != "desktop" || config.Proxies == nil {
+		t.Errorf("settings of the user config not kept: %s", content)
+	}
+	wantHelpers := map[string]string{"gcr.io": "gcloud", "ghcr.io": "", "https://index.docker.io/v1/": ""}
+	if !reflect.DeepEqual(config.CredHelpers, wantHelpers) {
+		t.Errorf("credHelpers = %v, want %v", config.CredHelpers, wantHelpers)
+	}
+	wantAuths := map[string]map[string]string{
+		"quay.io":                     {"auth": "b2xkOm9sZA=="},
+		"ghcr.io":                     {"auth": base64.StdEncoding.EncodeToString([]byte("deploy:s3cr3t"))},
+		"https://index.docker.io/v1/": {"auth": base64.StdEncoding.EncodeToString([]byte("hub:token"))},
+	}
+	if !reflect.DeepEqual(config.Auths, wantAuths) {
+		t.Errorf("auths = %v, want %v", config.Auths, wantAuths)
+	}
+
+	if after, _ := os.ReadFile(configPath); string(after) != user {
+		t.Errorf("user config changed: %s", after)
+	}
+}
+
+func TestRunConfigIsRemovedOnLogout(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	userConfig := os.Getenv("DOCKER_CONFIG")
+
+	if err := useRunConfig(map[string]RegistryCredential{"ghcr.io": {Username: "deploy", Password: "s3cr3t"}}); err != nil {
+		t.Fatal(err)
+	}
+	runDir := os.Getenv("DOCKER_CONFIG")
+	if runDir == userConfig {
+		t.Fatal("DOCKER_CONFIG not pointed at the run config")
+	}
+	if info, err := os.Stat(filepath.Join(runDir, "config.json")); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("run config not written privately: %v %v", info, err)
+	}
+
+	logoutRegistries()
+
+	if os.Getenv("DOCKER_CONFIG") != userConfig {
+		t.Errorf("DOCKER_CONFIG = %q, want %q", os.Getenv("DOCKER_CONFIG"), userConfig)
+	}
+	if _, err := os.Stat(runDir); !os.IsNotExist(err) {
+		t.Errorf("run config %s not removed: %v", runDir, err)
+	}
+}
+
+func TestRunConfigLinksPluginsAndContexts(t *testing.T) {
+	userConfig := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", userConfig)
+	plugin := filepath.Join(userConfig, "cli-plugins", "docker-compose")
+	if err := os.MkdirAll(filepath.Dir(plugin), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(plugin, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(userConfig, "contexts", "meta"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := useRunConfig(map[string]RegistryCredential{"ghcr.io": {Username: "deploy", Password: "s3cr3t"}}); err != nil {
+		t.Fatal(err)
+	}
+	runDir := os.Getenv("DOCKER_CONFIG")
+	if _, err := os.Stat(filepath.Join(runDir, "cli-plugins", "docker-compose")); err != nil {
+		t.Errorf("compose plugin not reachable from the run config: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(runDir, "contexts", "meta")); err != nil || !info.IsDir() {
+		t.Errorf("contexts not reachable from the run config: %v", err)
+	}
+
+	logoutRegistries()
+
+	// Removing the run config removes the links, not the directories of the user
+	if _, err := os.Stat(plugin); err != nil {
+		t.Errorf("compose plugin of the user removed: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"docker-deployment/src/compose"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/history"
+	"docker-deployment/src/runner"
+	"docker-deployment/src/utils"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultMigrationTimeout bounds a migration without timeout.
+const DefaultMigrationTimeout = 10 * time.Minute
+
+// migrationSettings is the migration of the compose file, with its timeout parsed.
+type migrationSettings struct {
+	service string
+	command []string
+	timeout time.Duration
+}
+
+// migration returns the migration declared in the compose file, or nil when there is none.
+func (s *Services) migration() (*migrationSettings, error) {
+	if s.Deployment == nil || s.Deployment.Migration == nil {
+		return nil, nil
+	}
+	config := s.Deployment.Migration
+
+	if _, ok := s.Services[config.Service]; !ok {
+		return nil, fmt.Errorf("migration service %q is not in the compose file", config.Service)
+	}
+	timeout, err := parseOptionalDuration(config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid migration timeout: %w", err)
+	}
+	if timeout <= 0 {
+		timeout = DefaultMigrationTimeout
+	}
+	return &migrationSettings{service: config.Service, command: config.Command, timeout: timeout}, nil
+}
+
+// migrationService returns the service of the migration when the migration runs the service itself rather than
+// a command in a service that is deployed too, or "" when there is none.
+func (s *Services) migrationService() string {
+	migration, err := s.migration()
+	if err != nil || migration == nil || len(migration.command) > 0 {
+		return ""
+	}
+	return migration.service
+}
+
+// upServices returns the services `up` has to be given so it leaves out the migration service, which already
+// ran before the deployment, or nil when `up` starts them all.
+func (s *Services) upServices() []string {
+	migrationService := s.migrationService()
+	if migrationService == "" {
+		return nil
+	}
+
+	var names []string
+	for _, name := range s.activeNames() {
+		if name != migrationService {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// runMigration runs the migration of the compose file in a new container that is removed afterwards,
+// streaming its output. It runs next to the services it depends on: in their project when they are
+// running already, otherwise in the project of the deployment, where compose starts them first and waits
+// for their depends_on conditions.
+func runMigration(project string, composePath string, services *Services) error {
+	migration, err := services.migration()
+	if err != nil || migration == nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migration.timeout)
+	defer cancel()
+
+	runProject, running, err := migrationProject(project, services, migration.service)
+	if err != nil {
+		return err
+	}
+	runArgs := []string{"run", "--rm"}
+	if running {
+		utils.Logger(utils.ColorBlue, "Dependencies of migration %s are running in %s", migration.service, runProject)
+		runArgs = append(runArgs, "--no-deps")
+	}
+	args := append(compose.ProjectArgs(runProject, composePath, append(runArgs, migration.service)...), migration.command...)
+	description := migration.service
+	if len(migration.command) > 0 {
+		description = fmt.Sprintf("%s (%s)", migration.service, strings.Join(migration.command, " "))
+	}
+
+	utils.Logger(utils.ColorBlue, "Running migration %s...", description)
+	output := &logWriter{color: utils.ColorBlue, prefix: migration.service + " | "}
+	started := time.Now()
+	_, err = compose.Stream(ctx, output, args...)
+	output.Flush()
+	duration := time.Since(started)
+
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if err != nil {
+		exitCode = -1
+	}
+	recordMigration(history.Migration{
+		Service:  migration.service,
+		Command:  migration.command,
+		ExitCode: exitCode,
+		Duration: duration.Round(time.Millisecond).Seconds(),
+	})
+
+	switch {
+	case runner.IsDryRun():
+		return nil
+	case ctx.Err() != nil:
+		return fmt.Errorf("migration %s did not finish within %s", description, migration.timeout)
+	case err != nil:
+		return fmt.Errorf("migration %s exited %d after %s", description, exitCode, duration.Round(time.Millisecond))
+	}
+
+	utils.Logger(utils.ColorGreen, "Migration %s exited 0 in %s", description, duration.Round(time.Millisecond))
+	return nil
+}
+
+// migrationProject returns the project the migration runs in, and whether the services it depends on are
+// running there already. Those services are found by their container name; the migration runs in their
+// project, on their network, when every one of them runs in the same project. Otherwise it runs in project.
+func migrationProject(project string, services *Services, service string) (string, bool, error) {
+	dependencies := services.Services[service].DependsOn.names()
+	if len(dependencies) == 0 {
+		return project, false, nil
+	}
+
+	running := ""
+	for _, name := range dependencies {
+		containerName := services.Services[name].ContainerName
+		if containerName == "" {
+			return project, false, nil
+		}
+		container, err := inspectContainer(containerName)
+		if err != nil {
+			return "", false, err
+		}
+		if container == nil || !container.State.Running {
+			return project, false, nil
+		}
+		containerProject := container.Config.Labels[engine.ComposeProjectLabel]
+		if containerProject == "" || running != "" && running != containerProject {
+			return project, false, nil
+		}
+		running = containerProject
+	}
+	return running, true, nil
+}
+
+// logWriter writes every complete line it receives through utils.Logger.
+type logWriter struct {
+	color   string
+	prefix  string
+	pending bytes.Buffer
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.pending.Write(p)
+	for {
+		line, err := w.pending.ReadString('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write
+			w.pending.Reset()
+			w.pending.WriteString(line)
+			return len(p), nil
+		}
+		utils.Logger(w.color, "%s%s", w.prefix, strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// Flush writes the last line when it does not end with a newline.
+func (w *logWriter) Flush() {
+	if w.pending.Len() > 0 {
+		utils.Logger(w.color, "%s%s", w.prefix, w.pending.String())
+		w.pending.Reset()
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"docker-deployment/src/compose"
+	"docker-deployment/src/runner"
+	"reflect"
+	"testing"
+)
+
+func TestRunMigrationProject(t *testing.T) {
+	const migrationCompose = `services:
+  app:
+    image: app:2
+    depends_on: {db: {condition: service_healthy}}
+  db:
+    image: postgres:16
+    container_name: db
+x-deployment:
+  migration:
+    service: app
+    command: [./manage, migrate]`
+
+	tests := []struct {
+		name       string
+		containers []*fakeContainer
+		want       string
+	}{
+		{
+			name: "first deployment starts the database",
+			want: "docker compose -p shop -f docker-compose.yaml run --rm app ./manage migrate",
+		},
+		{
+			name:       "database running in the previous project",
+			containers: []*fakeContainer{{ID: "0123456789ab", Name: "db", Project: "0f4c2a", Service: "db"}},
+			want:       "docker compose -p 0f4c2a -f docker-compose.yaml run --rm --no-deps app ./manage migrate",
+		},
+	}
+
+	compose.Use(&compose.Binary{Kind: compose.Plugin, Name: "docker", Args: []string{"compose"}})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			startFakeDaemon(t, test.containers...)
+			script := runner.NewScript().Expect("docker compose ")
+			runner.SetDefault(script)
+			t.Cleanup(func() { runner.SetDefault(runner.Exec{}) })
+
+			if err := runMigration("shop", "docker-compose.yaml", parseServices(t, migrationCompose)); err != nil {
+				t.Fatal(err)
+			}
+
+			recorded := script.Recorded()
+			if len(recorded) != 1 || recorded[0].String() != test.want {
+				t.Errorf("commands = %v, want %s", recorded, test.want)
+			}
+		})
+	}
+}
+
+func TestUpServices(t *testing.T) {
+	tests := []struct {
+		name    string
+		compose string
+		want    []string
+	}{
+		{
+			name:    "no migration",
+			compose: "services:\n  app: {image: app:2}\n  db: {image: postgres:16}\n",
+		},
+		{
+			name: "migration command in a deployed service",
+			compose: `services:
+  app: {image: app:2}
+  db: {image: postgres:16}
+x-deployment:
+  migration: {service: app, command: [./manage, migrate]}
+`,
+		},
+		{
+			name: "migration service",
+			compose: `services:
+  app: {image: app:2}
+  db: {image: postgres:16}
+  migrate: {image: app:2, command: [./manage, migrate], restart: "no"}
+x-deployment:
+  migration: {service: migrate}
+`,
+			want: []string{"app", "db"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseServices(t, test.compose).upServices(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("upServices() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dependencyLevels groups services so that every service comes after the services it depends on.
+// Services within a level do not depend on each other and are sorted by name. Services of profiles that
+// are not enabled are left out, and so are undefined services depended on with required: false.
+func dependencyLevels(services *Services) ([][]string, error) {
+	remaining := make(map[string][]string, len(services.Services))
+	for name, svc := range services.Services {
+		// Services of profiles that are not enabled are not started
+		if !svc.active() {
+			continue
+		}
+		var dependencies []string
+		for _, dependency := range svc.DependsOn.names() {
+			target, ok := services.Services[dependency]
+			if !ok && !svc.DependsOn[dependency].required() {
+				continue
+			}
+			if !ok {
+				return nil, fmt.Errorf("service %s depends on undefined service %s", name, dependency)
+			}
+			if target.active() {
+				dependencies = append(dependencies, dependency)
+			}
+		}
+		remaining[name] = dependencies
+	}
+
+	done := make(map[string]bool, len(remaining))
+	var levels [][]string
+
+	for len(remaining) > 0 {
+		var level []string
+		for name, dependencies := range remaining {
+			ready := true
+			for _, dependency := range dependencies {
+				if !done[dependency] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				level = append(level, name)
+			}
+		}
+
+		if len(level) == 0 {
+			var cycle []string
+			for name := range remaining {
+				cycle = append(cycle, name)
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("circular dependency between services %s", strings.Join(cycle, ", "))
+		}
+
+		sort.Strings(level)
+		for _, name := range level {
+			done[name] = true
+			delete(remaining, name)
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, nil
+}
+
+// withoutService removes the service from the dependency levels.
+func withoutService(levels [][]string, service string) [][]string {
+	filtered := make([][]string, 0, len(levels))
+	for _, level := range levels {
+		var kept []string
+		for _, name := range level {
+			if name != service {
+				kept = append(kept, name)
+			}
+		}
+		filtered = append(filtered, kept)
+	}
+	return filtered
+}
+
+// rollingBatches splits the dependency levels into batches of at most size services.
+func rollingBatches(levels [][]string, size int) [][]string {
+	if size < 1 {
+		size = 1
+	}
+
+	var batches [][]string
+	for _, level := range levels {
+		for start := 0; start < len(level); start += size {
+			end := start + size
+			if end > len(level) {
+				end = len(level)
+			}
+			batches = append(batches, level[start:end])
+		}
+	}
+	return batches
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseServices decodes a compose file written inline.
+func parseServices(t *testing.T, content string) *Services {
+	t.Helper()
+
+	var services Services
+	if err := yaml.Unmarshal([]byte(content), &services); err != nil {
+		t.Fatal(err)
+	}
+	return &services
+}
+
+func TestDependencyLevels(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles string
+		compose  string
+		want     [][]string
+		err      string
+	}{
+		{
+			name: "independent services share a level",
+			compose: `services:
+  web: {image: nginx}
+  api: {image: api}`,
+			want: [][]string{{"api", "web"}},
+		},
+		{
+			name: "dependencies come first",
+			compose: `services:
+  web: {depends_on: [api]}
+  api: {depends_on: {db: {condition: service_healthy}, cache: {condition: service_started}}}
+  db: {}
+  cache: {}
+  worker: {depends_on: [db]}`,
+			want: [][]string{{"cache", "db"}, {"api", "worker"}, {"web"}},
+		},
+		{
+			name: "inactive profiles are left out",
+			compose: `services:
+  web: {depends_on: [debug]}
+  debug: {profiles: [debug]}`,
+			want: [][]string{{"web"}},
+		},
+		{
+			name:     "enabled profiles are kept",
+			profiles: "debug",
+			compose: `services:
+  web: {depends_on: [debug]}
+  debug: {profiles: [debug]}`,
+			want: [][]string{{"debug"}, {"web"}},
+		},
+		{
+			name: "undefined dependency",
+			compose: `services:
+  web: {depends_on: [api]}`,
+			err: "service web depends on undefined service api",
+		},
+		{
+			name: "undefined optional dependency is skipped",
+			compose: `services:
+  web: {depends_on: {api: {condition: service_started}, cache: {condition: service_started, required: false}}}
+  api: {}`,
+			want: [][]string{{"api"}, {"web"}},
+		},
+		{
+			name: "defined optional dependency comes first",
+			compose: `services:
+  web: {depends_on: {cache: {condition: service_started, required: false}}}
+  cache: {}`,
+			want: [][]string{{"cache"}, {"web"}},
+		},
+		{
+			name: "cycle",
+			compose: `services:
+  web: {depends_on: [api]}
+  api: {depends_on: [web]}
+  db: {}`,
+			err: "circular dependency between services api, web",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("COMPOSE_PROFILES", test.profiles)
+
+			levels, err := dependencyLevels(parseServices(t, test.compose))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(levels, test.want) {
+				t.Errorf("levels = %v, want %v", levels, test.want)
+			}
+		})
+	}
+}
+
+func TestRollingBatches(t *testing.T) {
+	levels := [][]string{{"cache", "db", "queue"}, {"api"}}
+
+	got := rollingBatches(levels, 2)
+	want := [][]string{{"cache", "db"}, {"queue"}, {"api"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("batches = %v, want %v", got, want)
+	}
+}
+
+func TestRollingBatchesWithoutService(t *testing.T) {
+	levels := [][]string{{"db"}, {"migrate"}, {"api", "worker"}}
+
+	got := rollingBatches(withoutService(levels, "migrate"), 1)
+	want := [][]string{{"db"}, {"api"}, {"worker"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("batches = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/runner"
+	"docker-deployment/src/utils"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// pinnedComposeFile is the copy of the compose file with every image pinned to its digest, written next
+// to the copy of the original so both belong to the same compose project.
+const pinnedComposeFile = "docker-compose.pinned.yaml"
+
+// pinImages resolves the image of every service to the digest pulled, writes a copy of the compose file
+// with the pinned references to the deployment directory and returns its path with the pinned images by
+// service. Images without a digest, like the ones built locally, keep their tag.
+func pinImages(composePath string, services *Services) (string, map[string]string, error) {
+	client, err := engine.Default()
+	if err != nil {
+		return "", nil, err
+	}
+
+	pinned := map[string]string{}
+	for _, name := range services.activeNames() {
+		reference := services.Services[name].Image
+		if reference == "" || strings.Contains(reference, "@") {
+			continue
+		}
+
+		image, err := client.ImageInspect(context.Background(), reference)
+		if errors.Is(err, engine.ErrNotFound) {
+			if !runner.IsDryRun() {
+				utils.Logger(utils.ColorYellow, "Image %s of service %s not found locally, keeping its tag", reference, name)
+			}
+			continue
+		} else if err != nil {
+			return "", nil, fmt.Errorf("error inspecting image %s of service %s: %w", reference, name, err)
+		}
+
+		pinnedImage := pinnedReference(reference, image.ID)
+		if pinnedImage == image.ID {
+			utils.Logger(utils.ColorYellow, "Image %s of service %s has no registry digest, keeping its tag", reference, name)
+			continue
+		}
+		pinned[name] = pinnedImage
+	}
+
+	pinnedPath := filepath.Join(filepath.Dir(composePath), pinnedComposeFile)
+	if err := writePinnedCompose(composePath, pinnedPath, pinned); err != nil {
+		return "", nil, fmt.Errorf("error writing pinned compose file: %w", err)
+	}
+
+	if len(pinned) > 0 {
+		utils.Logger(utils.ColorBlue, "Pinned images:")
+		for _, name := range sortedKeys(pinned) {
+			utils.Logger(utils.ColorBlue, "  %-20s %s -> %s", name, services.Services[name].Image, pinned[name])
+		}
+	}
+	return pinnedPath, pinned, nil
+}
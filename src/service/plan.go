@@ -0,0 +1,551 @@
+package service
+
+import (
+	"context"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/history"
+	"docker-deployment/src/utils"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Plan actions for a service.
+const (
+	ActionCreate    = "create"
+	ActionRecreate  = "recreate"
+	ActionUnchanged = "unchanged"
+)
+
+// Plan formats.
+const (
+	PlanText     = "text"
+	PlanMarkdown = "markdown"
+)
+
+// ImageState is an image reference and the digest it resolves to.
+type ImageState struct {
+	Reference string
+	ID        string
+	Digest    string
+}
+
+// ServicePlan is what a deployment would do with a service.
+type ServicePlan struct {
+	Service       string
+	ContainerName string
+	Action        string
+	Reasons       []string
+	Current       *ImageState
+	Next          ImageState
+}
+
+// ContainerRemoval is a container a deployment would remove.
+type ContainerRemoval struct {
+	Name   string
+	ID     string
+	Reason string
+}
+
+// PrunePlan lists what Prune would delete.
+type PrunePlan struct {
+	Containers []string
+	Networks   []string
+	Images     []string
+}
+
+// DeploymentPlan describes the changes a deployment would make, computed without changing anything.
+type DeploymentPlan struct {
+	ComposeFile string
+	Strategy    string
+	Services    []ServicePlan
+	Removals    []ContainerRemoval
+	Prune       PrunePlan
+}
+
+// BuildPlan computes the deployment plan for config from the compose file and the live containers.
+func BuildPlan(ctx context.Context, config Config) (*DeploymentPlan, error) {
+	services, err := loadServicesFromFile(config.ComposeFile)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := engine.Default()
+	if err != nil {
+		return nil, err
+	}
+
+	strategy := config.Strategy
+	if strategy == "" {
+		strategy = StrategyRecreate
+	}
+	plan := &DeploymentPlan{ComposeFile: config.ComposeFile, Strategy: strategy}
+
+	liveColour := ""
+	if strategy == StrategyBlueGreen {
+		liveColour = activeColour(config.projectName())
+	}
+	project := deploymentProject(config, strategy)
+
+	for _, name := range services.activeNames() {
+		svc := services.Services[name]
+		servicePlan := ServicePlan{
+			Service:       name,
+			ContainerName: svc.ContainerName,
+			Next:          resolveImage(ctx, client, svc.Image),
+		}
+
+		existing, err := currentContainer(ctx, client, name, svc, config, liveColour)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			servicePlan.Current = &ImageState{
+				Reference: existing.Config.Image,
+				ID:        existing.Image,
+				Digest:    firstDigest(imageRepoDigests(existing.Image)),
+			}
+		}
+
+		servicePlan.Action, servicePlan.Reasons = planAction(servicePlan, svc, existing, project, config, strategy)
+		plan.Services = append(plan.Services, servicePlan)
+
+		if existing != nil && servicePlan.Action == ActionRecreate {
+			sameProject := project != "" && existing.Config.Labels[engine.ComposeProjectLabel] == project
+			plan.Removals = append(plan.Removals, ContainerRemoval{
+				Name:   existing.ShortName(),
+				ID:     existing.ID,
+				Reason: removalReason(strategy, config.Force, sameProject),
+			})
+		}
+	}
+
+	if plan.Prune, err = planPrune(ctx, client); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// deploymentProject returns the compose project the deployment runs the services in, or "" when every run
+// deploys a new one.
+func deploymentProject(config Config, strategy string) string {
+	switch strategy {
+	case StrategyRolling:
+		return config.projectName()
+	case StrategyBlueGreen:
+		return ""
+	default:
+		// The compose file is copied to a new deployment directory, so only a declared name is kept across runs
+		return utils.DeclaredProjectName(config.ComposeFile)
+	}
+}
+
+// currentContainer returns the container currently serving the service, if any: the one compose labelled
+// with the project and the service, or else the one holding the container name of the service.
+func currentContainer(ctx context.Context, client *engine.Client, name string, svc Service, config Config, liveColour string) (*engine.ContainerJSON, error) {
+	project, containerName := config.projectName(), svc.ContainerName
+	if liveColour != "" {
+		project = fmt.Sprintf("%s-%s", project, liveColour)
+		if containerName != "" {
+			containerName = fmt.Sprintf("%s-%s", containerName, liveColour)
+		}
+	}
+
+	containers, err := client.ContainerList(ctx, true, engine.ProjectFilter(project, name))
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) > 0 {
+		// A running container serves the service rather than a stopped one left behind
+		current := containers[0]
+		for _, container := range containers {
+			if container.State == "running" {
+				current = container
+				break
+			}
+		}
+		return inspectContainer(current.ID)
+	}
+
+	if containerName == "" {
+		return nil, nil
+	}
+	return inspectContainer(containerName)
+}
+
+func planAction(servicePlan ServicePlan, svc Service, existing *engine.ContainerJSON, project string, config Config, strategy string) (string, []string) {
+	if existing == nil {
+		if strategy == StrategyBlueGreen {
+			return ActionCreate, []string{"no live colour"}
+		}
+		return ActionCreate, []string{"no running container"}
+	}
+
+	owner := existing.Config.Labels[engine.ComposeProjectLabel]
+	if strategy != StrategyBlueGreen && svc.ContainerName == "" && (project == "" || owner != project) {
+		// Without a container name nothing conflicts, the container of the other project keeps running
+		return ActionCreate, []string{fmt.Sprintf("new project, the container of project %s keeps running", owner)}
+	}
+
+	var reasons []string
+	current, next := servicePlan.Current, servicePlan.Next
+
+	if current.Reference != next.Reference {
+		reasons = append(reasons, fmt.Sprintf("image %s -> %s", current.Reference, next.Reference))
+	} else if next.Digest != "" && current.Digest != "" && current.Digest != next.Digest {
+		reasons = append(reasons, fmt.Sprintf("digest %s -> %s", shortDigest(current.Digest), shortDigest(next.Digest)))
+	} else if next.ID != "" && current.ID != next.ID {
+		reasons = append(reasons, "local image changed")
+	}
+	reasons = append(reasons, configChanges(svc, existing)...)
+	if config.Force {
+		reasons = append(reasons, "FORCE is set")
+	}
+
+	switch {
+	case strategy == StrategyBlueGreen:
+		reasons = append(reasons, "new colour replaces the live one")
+	case project == "":
+		reasons = append(reasons, "every run deploys a new compose project")
+	case owner != project:
+		reasons = append(reasons, fmt.Sprintf("container name owned by project %s", owner))
+	}
+
+	if len(reasons) == 0 {
+		return ActionUnchanged, nil
+	}
+	return ActionRecreate, reasons
+}
+
+// configChanges lists the settings of the service the container does not run with. Settings the compose file
+// leaves to the image are not compared.
+func configChanges(svc Service, existing *engine.ContainerJSON) []string {
+	var changes []string
+
+	if len(svc.Command) > 0 && !sameArgs(svc.Command, existing.Config.Cmd) {
+		changes = append(changes, "command changed")
+	}
+	if len(svc.Entrypoint) > 0 && !sameArgs(svc.Entrypoint, existing.Config.Entrypoint) {
+		changes = append(changes, "entrypoint changed")
+	}
+
+	env := map[string]string{}
+	for _, entry := range existing.Config.Env {
+		key, value, _ := strings.Cut(entry, "=")
+		env[key] = value
+	}
+	environment := map[string]string{}
+	for key, value := range svc.Environment {
+		if value != nil {
+			environment[key] = os.ExpandEnv(*value)
+		} else if hostValue, ok := os.LookupEnv(key); ok {
+			environment[key] = hostValue
+		}
+	}
+	for _, key := range sortedKeys(environment) {
+		if value, ok := env[key]; !ok || value != environment[key] {
+			changes = append(changes, fmt.Sprintf("environment %s changed", key))
+		}
+	}
+
+	for _, key := range sortedKeys(svc.Labels) {
+		if value, ok := existing.Config.Labels[key]; !ok || value != os.ExpandEnv(svc.Labels[key]) {
+			changes = append(changes, fmt.Sprintf("label %s changed", key))
+		}
+	}
+
+	if svc.User != "" && svc.User != existing.Config.User {
+		changes = append(changes, fmt.Sprintf("user %s -> %s", existing.Config.User, svc.User))
+	}
+	if svc.WorkingDir != "" && svc.WorkingDir != existing.Config.WorkingDir {
+		changes = append(changes, fmt.Sprintf("working_dir %s -> %s", existing.Config.WorkingDir, svc.WorkingDir))
+	}
+	if restart := restartPolicy(svc.Restart); restart != restartPolicy(existing.HostConfig.RestartPolicy.Name) {
+		changes = append(changes, fmt.Sprintf("restart %s -> %s", restartPolicy(existing.HostConfig.RestartPolicy.Name), restart))
+	}
+
+	return changes
+}
+
+// sameArgs compares a command of the compose file with the one of a container. The string form of the compose
+// file is split like a shell would, ignoring the quotes.
+func sameArgs(serviceArgs StringOrList, containerArgs []string) bool {
+	if len(serviceArgs) > 1 {
+		return slices.Equal([]string(serviceArgs), containerArgs)
+	}
+	unquote := strings.NewReplacer(`"`, "", `'`, "")
+	return strings.Join(strings.Fields(unquote.Replace(serviceArgs[0])), " ") ==
+		strings.Join(strings.Fields(unquote.Replace(strings.Join(containerArgs, " "))), " ")
+}
+
+// restartPolicy returns the name of a restart policy, "no" when it is not set.
+func restartPolicy(restart string) string {
+	name, _, _ := strings.Cut(restart, ":")
+	if name == "" {
+		return "no"
+	}
+	return name
+}
+
+func removalReason(strategy string, force bool, sameProject bool) string {
+	switch {
+	case strategy == StrategyBlueGreen:
+		return "live colour removed after the swap"
+	case sameProject:
+		return "recreated by docker compose in its project"
+	case force:
+		return "name conflict, retired and removed after a successful deployment"
+	default:
+		return "name conflict, deployment fails without FORCE"
+	}
+}
+
+// resolveImage returns the local image and the digest the registry serves for reference.
+func resolveImage(ctx context.Context, client *engine.Client, reference string) ImageState {
+	state := ImageState{Reference: reference}
+	if reference == "" {
+		return state
+	}
+
+	if image, err := client.ImageInspect(ctx, reference); err == nil {
+		state.ID = image.ID
+		state.Digest = firstDigest(image.RepoDigests)
+	}
+	if digest, err := client.DistributionInspect(ctx, reference); err == nil {
+		state.Digest = digest
+	}
+	return state
+}
+
+// planPrune lists what `docker system prune -f` would delete right now.
+func planPrune(ctx context.Context, client *engine.Client) (PrunePlan, error) {
+	var prune PrunePlan
+
+	containers, err := client.ContainerList(ctx, true, nil)
+	if err != nil {
+		return prune, err
+	}
+
+	// Containers are pruned first, so their networks count as unused afterwards
+	usedNetworks := map[string]bool{}
+	for _, container := range containers {
+		// The history accessors are excluded from the prune
+		if _, ok := container.Labels[history.Label]; ok {
+			continue
+		}
+		switch container.State {
+		case "created", "exited", "dead":
+			prune.Containers = append(prune.Containers, container.Name())
+			continue
+		}
+		for name := range container.NetworkSettings.Networks {
+			usedNetworks[name] = true
+		}
+	}
+
+	networks, err := client.NetworkList(ctx, nil)
+	if err != nil {
+		return prune, err
+	}
+	for _, network := range networks {
+		if !network.Predefined() && !usedNetworks[network.Name] {
+			prune.Networks = append(prune.Networks, network.Name)
+		}
+	}
+
+	images, err := client.ImageList(ctx, map[string][]string{"dangling": {"true"}})
+	if err != nil {
+		return prune, err
+	}
+	for _, image := range images {
+		prune.Images = append(prune.Images, shortDigest(image.ID))
+	}
+
+	sort.Strings(prune.Containers)
+	sort.Strings(prune.Networks)
+	return prune, nil
+}
+
+// Render formats the plan as text or markdown.
+func (p *DeploymentPlan) Render(format string) string {
+	if format == PlanMarkdown {
+		return p.markdown()
+	}
+	return p.text()
+}
+
+func (p *DeploymentPlan) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Deployment plan for %s (strategy: %s)\n\n", p.ComposeFile, p.Strategy)
+
+	fmt.Fprintln(&b, "Services:")
+	for _, servicePlan := range p.Services {
+		fmt.Fprintf(&b, "  %s %s%s\n", actionSymbol(servicePlan.Action), servicePlan.Service, containerSuffix(servicePlan))
+		fmt.Fprintf(&b, "      image: %s\n", imageChange(servicePlan))
+		for _, reason := range servicePlan.Reasons {
+			fmt.Fprintf(&b, "      - %s\n", reason)
+		}
+	}
+
+	fmt.Fprintln(&b, "\nContainers removed:")
+	if len(p.Removals) == 0 {
+		fmt.Fprintln(&b, "  none")
+	}
+	for _, removal := range p.Removals {
+		fmt.Fprintf(&b, "  - %s (%s): %s\n", removal.Name, shortDigest(removal.ID), removal.Reason)
+	}
+
+	fmt.Fprintln(&b, "\nPrune would delete:")
+	fmt.Fprintf(&b, "  containers: %s\n", listOrNone(p.Prune.Containers))
+	fmt.Fprintf(&b, "  networks:   %s\n", listOrNone(p.Prune.Networks))
+	fmt.Fprintf(&b, "  images:     %s\n", listOrNone(p.Prune.Images))
+
+	fmt.Fprintf(&b, "\n%s\n", p.summary())
+	return b.String()
+}
+
+func (p *DeploymentPlan) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Deployment plan for `%s`\n\n", p.ComposeFile)
+	fmt.Fprintf(&b, "Strategy: `%s` — %s\n\n", p.Strategy, p.summary())
+
+	fmt.Fprintln(&b, "| Service | Container | Action | Image | Reasons |")
+	fmt.Fprintln(&b, "|---|---|---|---|---|")
+	for _, servicePlan := range p.Services {
+		fmt.Fprintf(&b, "| `%s` | %s | **%s** | %s | %s |\n",
+			servicePlan.Service,
+			markdownCode(servicePlan.ContainerName),
+			servicePlan.Action,
+			strings.ReplaceAll(imageChange(servicePlan), "|", "\\|"),
+			strings.Join(servicePlan.Reasons, "<br>"))
+	}
+
+	if len(p.Removals) > 0 {
+		fmt.Fprintln(&b, "\n#### Containers removed")
+		for _, removal := range p.Removals {
+			fmt.Fprintf(&b, "- `%s` (`%s`): %s\n", removal.Name, shortDigest(removal.ID), removal.Reason)
+		}
+	}
+
+	fmt.Fprintln(&b, "\n#### Prune")
+	fmt.Fprintf(&b, "- Containers: %s\n", markdownList(p.Prune.Containers))
+	fmt.Fprintf(&b, "- Networks: %s\n", markdownList(p.Prune.Networks))
+	fmt.Fprintf(&b, "- Images: %s\n", markdownList(p.Prune.Images))
+	return b.String()
+}
+
+func (p *DeploymentPlan) summary() string {
+	counts := map[string]int{}
+	for _, servicePlan := range p.Services {
+		counts[servicePlan.Action]++
+	}
+	return fmt.Sprintf("%d to create, %d to recreate, %d unchanged, %d containers removed",
+		counts[ActionCreate], counts[ActionRecreate], counts[ActionUnchanged], len(p.Removals))
+}
+
+func actionSymbol(action string) string {
+	switch action {
+	case ActionCreate:
+		return "+"
+	case ActionRecreate:
+		return "~"
+	default:
+		return "="
+	}
+}
+
+func containerSuffix(servicePlan ServicePlan) string {
+	if servicePlan.ContainerName == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", servicePlan.ContainerName)
+}
+
+func imageChange(servicePlan ServicePlan) string {
+	next := describeImage(servicePlan.Next)
+	if servicePlan.Current == nil {
+		return next
+	}
+	current := describeImage(*servicePlan.Current)
+	if current == next {
+		return current
+	}
+	return fmt.Sprintf("%s -> %s", current, next)
+}
+
+func describeImage(image ImageState) string {
+	if image.Digest == "" {
+		return image.Reference + " (digest unknown)"
+	}
+	return fmt.Sprintf("%s@%s", image.Reference, shortDigest(image.Digest))
+}
+
+// firstDigest returns the digest part of the first repo digest.
+func firstDigest(repoDigests []string) string {
+	if len(repoDigests) == 0 {
+		return ""
+	}
+	if _, digest, ok := strings.Cut(repoDigests[0], "@"); ok {
+		return digest
+	}
+	return repoDigests[0]
+}
+
+func shortDigest(digest string) string {
+	algorithm, hex, ok := strings.Cut(digest, ":")
+	if !ok {
+		return digest
+	}
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+	return algorithm + ":" + hex
+}
+
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
+
+func markdownList(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	var quoted []string
+	for _, value := range values {
+		quoted = append(quoted, "`"+value+"`")
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func markdownCode(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return "`" + value + "`"
+}
+
+// PrintPlan prints the deployment plan for config without changing anything.
+func PrintPlan(config Config, format string) {
+	if err := config.validate(); err != nil {
+		utils.Logger(utils.ColorRed, "Invalid configuration: %s", err)
+		os.Exit(1)
+	}
+	if format != "" && format != PlanText && format != PlanMarkdown {
+		utils.Logger(utils.ColorRed, "Invalid PLAN_FORMAT %q, expected %s or %s", format, PlanText, PlanMarkdown)
+		os.Exit(1)
+	}
+
+	plan, err := BuildPlan(context.Background(), config)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error building deployment plan: %s", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(plan.Render(format))
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuildPlanFindsServicesByLabels(t *testing.T) {
+	const namedCompose = `name: shop
+services:
+  web:
+    image: nginx:1.27
+    environment:
+      MODE: production
+  api:
+    container_name: shop-api
+    image: shop/api:2.0
+`
+	tests := []struct {
+		name        string
+		content     string
+		containers  []*fakeContainer
+		wantActions map[string]string
+		wantReasons map[string][]string
+		wantRemoved []string
+	}{
+		{
+			name:    "unchanged in the project",
+			content: namedCompose,
+			containers: []*fakeContainer{
+				{ID: "web1", Name: "shop-web-1", Project: "shop", Service: "web", Image: "nginx:1.27", Env: []string{"MODE=production", "PATH=/usr/bin"}},
+				{ID: "api1", Name: "shop-api", Project: "shop", Service: "api", Image: "shop/api:2.0"},
+			},
+			wantActions: map[string]string{"web": ActionUnchanged, "api": ActionUnchanged},
+			wantReasons: map[string][]string{},
+		},
+		{
+			name:    "config changed",
+			content: namedCompose,
+			containers: []*fakeContainer{
+				{ID: "web1", Name: "shop-web-1", Project: "shop", Service: "web", Image: "nginx:1.27", Env: []string{"MODE=staging"}},
+				{ID: "api1", Name: "shop-api", Project: "shop", Service: "api", Image: "shop/api:1.0"},
+			},
+			wantActions: map[string]string{"web": ActionRecreate, "api": ActionRecreate},
+			wantReasons: map[string][]string{
+				"web": {"environment MODE changed"},
+				"api": {"image shop/api:1.0 -> shop/api:2.0"},
+			},
+			wantRemoved: []string{"shop-api", "shop-web-1"},
+		},
+		{
+			name:    "container name owned by another project",
+			content: namedCompose,
+			containers: []*fakeContainer{
+				{ID: "api1", Name: "shop-api", Project: "legacy", Service: "api", Image: "shop/api:2.0"},
+			},
+			wantActions: map[string]string{"web": ActionCreate, "api": ActionRecreate},
+			wantReasons: map[string][]string{
+				"web": {"no running container"},
+				"api": {"container name owned by project legacy"},
+			},
+			wantRemoved: []string{"shop-api"},
+		},
+		{
+			name:    "new project on every run",
+			content: "services:\n  web:\n    image: nginx:1.27\n",
+			containers: []*fakeContainer{
+				{ID: "web1", Name: "shop-web-1", Project: "shop", Service: "web", Image: "nginx:1.27"},
+			},
+			wantActions: map[string]string{"web": ActionCreate},
+			wantReasons: map[string][]string{"web": {"new project, the container of project shop keeps running"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("COMPOSE_PROJECT_NAME", "")
+			startFakeDaemon(t, test.containers...)
+
+			dir := filepath.Join(t.TempDir(), "shop")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			composeFile := filepath.Join(dir, "docker-compose.yaml")
+			if err := os.WriteFile(composeFile, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			plan, err := BuildPlan(context.Background(), Config{ComposeFile: composeFile})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actions, reasons := map[string]string{}, map[string][]string{}
+			for _, servicePlan := range plan.Services {
+				actions[servicePlan.Service] = servicePlan.Action
+				if len(servicePlan.Reasons) > 0 {
+					reasons[servicePlan.Service] = servicePlan.Reasons
+				}
+			}
+			if !reflect.DeepEqual(actions, test.wantActions) {
+				t.Errorf("actions = %v, want %v", actions, test.wantActions)
+			}
+			if !reflect.DeepEqual(reasons, test.wantReasons) {
+				t.Errorf("reasons = %v, want %v", reasons, test.wantReasons)
+			}
+
+			var removed []string
+			for _, removal := range plan.Removals {
+				removed = append(removed, removal.Name)
+			}
+			if !reflect.DeepEqual(removed, test.wantRemoved) {
+				t.Errorf("removed = %v, want %v", removed, test.wantRemoved)
+			}
+		})
+	}
+}
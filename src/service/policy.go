@@ -0,0 +1,64 @@
+package service
+
+import (
+	"docker-deployment/src/policy"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// checkPolicy evaluates the services of the compose file against the policy file of config, or the
+// default policy, prints the violations and returns an error when a rule set to deny is broken.
+func checkPolicy(config Config) error {
+	rules := policy.Default()
+	if config.PolicyFile != "" {
+		var err error
+		if rules, err = policy.Load(config.PolicyFile); err != nil {
+			return err
+		}
+	}
+
+	// Allowed registries are matched like the registries of images, so index.docker.io allows nginx
+	for i, registry := range rules.AllowedRegistries {
+		rules.AllowedRegistries[i] = normalizeRegistry(registry)
+	}
+
+	services, err := loadServicesFromFile(config.ComposeFile)
+	if err != nil {
+		return err
+	}
+
+	report := rules.Evaluate(services.policyServices())
+	report.Print()
+	if denied := report.Denied(); len(denied) > 0 {
+		return fmt.Errorf("%d policy violations denied", len(denied))
+	}
+	return nil
+}
+
+// policyServices returns what the policy rules need to know about the services started.
+func (s *Services) policyServices() []policy.Service {
+	services := make([]policy.Service, 0, len(s.Services))
+	for _, name := range s.activeNames() {
+		service := s.Services[name]
+		policyService := policy.Service{
+			Name:           name,
+			Image:          service.Image,
+			ContainerName:  service.ContainerName,
+			HasHealthCheck: service.HealthCheck != nil && !service.HealthCheck.disabled(),
+			Privileged:     service.Privileged,
+			NetworkMode:    service.NetworkMode,
+		}
+		if service.Image != "" {
+			policyService.Registry = imageRegistry(service.Image)
+		}
+		for _, volume := range service.Volumes {
+			// A source given by a variable, like ${DOCKER_SOCK}, is only known to be a path once expanded
+			if volume.Type == VolumeTypeBind || volume.Type == VolumeTypeVolume && strings.HasPrefix(os.ExpandEnv(volume.Source), "/") {
+				policyService.Mounts = append(policyService.Mounts, volume.Source)
+			}
+		}
+		services = append(services, policyService)
+	}
+	return services
+}
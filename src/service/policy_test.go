@@ -0,0 +1,48 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPolicyServicesMounts(t *testing.T) {
+	t.Setenv("DOCKER_SOCK", "/var/run/docker.sock")
+	services := parseServices(t, `services:
+  web:
+    image: nginx:1.27
+    volumes:
+      - ${DOCKER_SOCK}:/var/run/docker.sock
+      - ./html:/usr/share/nginx/html:ro
+      - data:/data
+      - type: bind
+        source: /var/run
+        target: /host/run
+      - type: volume
+        source: cache
+        target: /cache`)
+
+	want := []string{"${DOCKER_SOCK}", "./html", "/var/run"}
+	if got := services.policyServices()[0].Mounts; !reflect.DeepEqual(got, want) {
+		t.Errorf("mounts = %v, want %v", got, want)
+	}
+}
+
+func TestCheckPolicyNormalizesAllowedRegistries(t *testing.T) {
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, "docker-compose.yaml")
+	policyPath := filepath.Join(dir, "policy.yaml")
+	compose := "services:\n  web:\n    image: nginx:1.27\n"
+	rules := "rules:\n  registry_allowlist: deny\nallowed_registries: [https://index.docker.io/v1/]\n"
+	if err := os.WriteFile(composePath, []byte(compose), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(policyPath, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkPolicy(Config{ComposeFile: composePath, PolicyFile: policyPath}); err != nil {
+		t.Errorf("checkPolicy() = %v", err)
+	}
+}
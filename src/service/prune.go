@@ -1,27 +1,63 @@
 package service
 
 import (
+	"context"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/history"
 	"docker-deployment/src/utils"
-	"os/exec"
+	"fmt"
+	"strings"
 )
 
-func Prune(arg ...string) error {
-	// Prepare docker-compose command with optional --force-recreate
+func Prune(filters ...string) error {
+	client, err := engine.Default()
+	if err != nil {
+		return err
+	}
 
-	cmdArgs := []string{"system", "prune", "-f"}
-	cmdArgs = append(cmdArgs, arg...)
+	// Filters use the docker CLI syntax, e.g. "until=24h". The history accessors are stopped containers
+	// that another runner may be using, so they are never pruned
+	pruneFilters := map[string][]string{"label!": {history.Label}}
+	for _, filter := range filters {
+		key, value, _ := strings.Cut(filter, "=")
+		pruneFilters[key] = append(pruneFilters[key], value)
+	}
 
 	utils.Logger(utils.ColorYellow, "Running docker system prune -f...")
-	cmd := exec.Command("docker", cmdArgs...)
-	output, err := cmd.CombinedOutput()
+	report, err := client.SystemPrune(context.Background(), pruneFilters)
 
 	if err != nil {
-		utils.Logger(utils.ColorRed, "docker system prune -f : %s", string(output))
+		utils.Logger(utils.ColorRed, "docker system prune -f : %s", err)
 		return err
 	}
 
 	utils.Logger(utils.ColorYellow, "docker system prune -f completed successful")
-	utils.Logger("", string(output))
+	for _, id := range report.ContainersDeleted {
+		utils.Logger("", "Deleted container: %s", id)
+	}
+	for _, name := range report.NetworksDeleted {
+		utils.Logger("", "Deleted network: %s", name)
+	}
+	for _, id := range report.ImagesDeleted {
+		utils.Logger("", "Deleted image: %s", id)
+	}
+	for _, id := range report.BuildCacheDeleted {
+		utils.Logger("", "Deleted build cache object: %s", id)
+	}
+	utils.Logger("", "Total reclaimed space: %s", formatBytes(report.SpaceReclaimed))
 
 	return nil
 }
+
+func formatBytes(size uint64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := uint64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.3g%cB", float64(size)/float64(div), "kMGTPE"[exp])
+}
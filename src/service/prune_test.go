@@ -0,0 +1,26 @@
+package service
+
+import (
+	"docker-deployment/src/history"
+	"reflect"
+	"testing"
+)
+
+func TestPruneKeepsHistoryAccessors(t *testing.T) {
+	daemon := startFakeDaemon(t)
+
+	if err := Prune("until=24h"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{"label!": {history.Label}, "until": {"24h"}}
+	if len(daemon.pruneFilters) == 0 {
+		t.Fatal("nothing pruned")
+	}
+	for _, filters := range daemon.pruneFilters {
+		// The build cache prune takes no filters
+		if filters != nil && !reflect.DeepEqual(filters, want) {
+			t.Errorf("prune filters = %v, want %v", filters, want)
+		}
+	}
+}
@@ -1,21 +1,146 @@
 package service
 
 import (
+	"context"
+	"docker-deployment/src/compose"
 	"docker-deployment/src/utils"
-	"os/exec"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
-func Pull(dockerComposeFile string) error {
-	// Prepare docker-compose command with optional --force-recreate
-	cmdArgs := []string{"-f", dockerComposeFile, "pull"}
+const (
+	// DefaultPullConcurrency is the number of images pulled at the same time.
+	DefaultPullConcurrency = 4
+	// DefaultPullRetries is the number of extra attempts of a pull failing with a transient error.
+	DefaultPullRetries = 3
+	// pullBackoff is the pause before the first retry of a pull, doubled on every retry up to maxPullBackoff.
+	pullBackoff    = 2 * time.Second
+	maxPullBackoff = 30 * time.Second
+)
+
+// permanentPullErrors are the registry answers a retry does not change.
+var permanentPullErrors = []string{
+	"not found",
+	"manifest unknown",
+	"unauthorized",
+	"denied",
+	"authentication required",
+	"invalid reference format",
+}
+
+// PullOptions tunes the pull phase of a deployment.
+type PullOptions struct {
+	Concurrency int
+	Retries     int
+}
+
+// pullOptions tunes the pull phase of the running deployment.
+var pullOptions = PullOptions{Concurrency: DefaultPullConcurrency, Retries: DefaultPullRetries}
+
+// Pull pulls the images of the services in parallel, retrying transient registry errors with backoff.
+// Services sharing an image pull it once, services without image or of profiles that are not enabled are
+// skipped. It returns an error naming every image that could not be pulled.
+func Pull(project string, composePath string, services *Services, options PullOptions) error {
+	// One service per image is enough to pull it
+	byImage := map[string]string{}
+	for _, name := range services.activeNames() {
+		image := services.Services[name].Image
+		if _, ok := byImage[image]; image != "" && !ok {
+			byImage[image] = name
+		}
+	}
+	if len(byImage) == 0 {
+		return nil
+	}
+
+	images := make([]string, 0, len(byImage))
+	for image := range byImage {
+		images = append(images, image)
+	}
+	sort.Strings(images)
 
-	utils.Logger(utils.ColorBlue, "Running docker-compose -f %s pull...", dockerComposeFile)
-	cmd := exec.Command("docker-compose", cmdArgs...)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		utils.Logger(utils.ColorRed, "docker-compose -f %s pull failed: %s", dockerComposeFile, string(output))
-		return err
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultPullConcurrency
 	}
-	utils.Logger(utils.ColorBlue, "docker-compose -f %s pull completed successful", dockerComposeFile)
 
+	utils.Logger(utils.ColorBlue, "Pulling %d images, %d at a time...", len(images), concurrency)
+	started := time.Now()
+
+	var (
+		waitGroup sync.WaitGroup
+		mutex     sync.Mutex
+		failures  []string
+	)
+	slots := make(chan struct{}, concurrency)
+	for _, image := range images {
+		waitGroup.Add(1)
+		go func(image string) {
+			defer waitGroup.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			if err := pullService(project, composePath, byImage[image], image, options.Retries); err != nil {
+				mutex.Lock()
+				failures = append(failures, fmt.Sprintf("%s: %s", image, err))
+				mutex.Unlock()
+			}
+		}(image)
+	}
+	waitGroup.Wait()
+
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return fmt.Errorf("error pulling %d of %d images: %s", len(failures), len(images), strings.Join(failures, "; "))
+	}
+
+	utils.Logger(utils.ColorGreen, "Pulled %d images in %s", len(images), time.Since(started).Round(time.Millisecond))
 	return nil
 }
+
+// pullService pulls the image of a service, retrying up to retries times when the error looks transient.
+func pullService(project string, composePath string, serviceName string, image string, retries int) error {
+	backoff := pullBackoff
+	for attempt := 0; ; attempt++ {
+		output, err := compose.Run(context.Background(), compose.ProjectArgs(project, composePath, "pull", "--quiet", serviceName)...)
+		if err == nil {
+			utils.Logger(utils.ColorGreen, "Pulled %s", image)
+			return nil
+		}
+
+		message := strings.TrimSpace(string(output))
+		if message == "" {
+			message = err.Error()
+		}
+		if attempt >= retries || !transientPullError(message) {
+			utils.Logger(utils.ColorRed, "Error pulling %s: %s", image, message)
+			return fmt.Errorf("%s", lastLine(message))
+		}
+
+		utils.Logger(utils.ColorYellow, "Error pulling %s, retrying in %s (%d/%d): %s", image, backoff, attempt+1, retries,
+			lastLine(message))
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxPullBackoff {
+			backoff = maxPullBackoff
+		}
+	}
+}
+
+// transientPullError reports whether a pull failing with message may succeed when retried.
+func transientPullError(message string) bool {
+	message = strings.ToLower(message)
+	for _, permanent := range permanentPullErrors {
+		if strings.Contains(message, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+func lastLine(value string) string {
+	lines := strings.Split(strings.TrimSpace(value), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
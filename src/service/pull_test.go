@@ -0,0 +1,26 @@
+package service
+
+import "testing"
+
+func TestTransientPullError(t *testing.T) {
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{"Get \"https://registry-1.docker.io/v2/\": net/http: TLS handshake timeout", true},
+		{"received unexpected HTTP status: 503 Service Unavailable", true},
+		{"toomanyrequests: You have reached your pull rate limit", true},
+		{"dial tcp: lookup ghcr.io: i/o timeout", true},
+		{"manifest for nginx:9.9 not found: manifest unknown", false},
+		{"pull access denied for acme/api, repository does not exist", false},
+		{"Head \"https://ghcr.io/v2/acme/api/manifests/1\": unauthorized", false},
+		{"invalid reference format", false},
+		{"Error response from daemon: Authentication Required", false},
+	}
+
+	for _, test := range tests {
+		if got := transientPullError(test.message); got != test.want {
+			t.Errorf("transientPullError(%q) = %t, want %t", test.message, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"docker-deployment/src/compose"
+	"docker-deployment/src/history"
+	"docker-deployment/src/utils"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// rollingRun updates the services one batch at a time in dependency order, validating each batch
+// before moving on to the next one.
+func rollingRun(config Config, timeout time.Duration) error {
+	_ = Prune()
+
+	// Batches replace the services of the running project in place, so the project must be the same on
+	// every run rather than the one of the new deployment directory
+	current, err := prepareDeployment(config.ComposeFile, config.projectName())
+	if err != nil {
+		return err
+	}
+
+	levels, err := dependencyLevels(current.Services)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error ordering services: %s", err)
+		return exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	// The migration service already ran before the batches
+	if migrationService := current.Services.migrationService(); migrationService != "" {
+		levels = withoutService(levels, migrationService)
+	}
+
+	batches := rollingBatches(levels, config.BatchSize)
+	var updated []string
+
+	for index, batch := range batches {
+		utils.Logger(utils.ColorBlue, "Rolling batch %d/%d: %s", index+1, len(batches), strings.Join(batch, ", "))
+
+		batchDir := fmt.Sprintf("%s/batch-%d", current.Dir, index+1)
+		if err := os.MkdirAll(batchDir, os.ModePerm); err != nil {
+			utils.Logger(utils.ColorRed, "Error creating directory: %s", err)
+			return haltRollout(updated, batch)
+		}
+
+		batchServices := &Services{Services: make(map[string]Service, len(batch))}
+		for _, name := range batch {
+			batchServices.Services[name] = current.Services.Services[name]
+		}
+
+		snapshot, err := CaptureSnapshot(batchDir, current.Project, batchServices)
+		if err != nil {
+			utils.Logger(utils.ColorRed, "Error capturing running containers: %s", err)
+			return haltRollout(updated, batch)
+		}
+
+		err = composeUp(current.Project, current.ComposePath, config.Force, snapshot, len(batch), batch...)
+		if err == nil {
+			var containerMap map[string]string
+			containerMap, err = GetContainers(current.Project, batch...)
+			if err == nil {
+				err = validateDeployment(containerMap, current.Project, current.ComposePath, config.ComposeFile, timeout)
+			}
+			if err == nil {
+				recordImages(containerMap)
+			}
+		}
+
+		// Smoke tests run once the whole stack is updated, a failure restores the last batch
+		if err == nil && index == len(batches)-1 {
+			var containerMap map[string]string
+			if containerMap, err = GetContainers(current.Project); err == nil {
+				err = runSmokeTests(current.Project, current.ComposePath, containerMap)
+			}
+		}
+
+		if err != nil {
+			utils.Logger(utils.ColorRed, "Batch %s failed: %s", strings.Join(batch, ", "), err)
+			restoreBatch(current.Project, current.ComposePath, snapshot, batch, timeout)
+			return haltRollout(updated, batch)
+		}
+
+		snapshot.Discard()
+		updated = append(updated, batch...)
+	}
+
+	_ = Prune()
+	utils.Logger(utils.ColorGreen, "Rolling update completed: %s", strings.Join(updated, ", "))
+	finishRecord(history.OutcomeSuccess, "")
+	return nil
+}
+
+// restoreBatch removes the failed batch and brings back the containers it replaced.
+func restoreBatch(project string, tempPath string, snapshot *Snapshot, batch []string, timeout time.Duration) {
+	cmdArgs := append(compose.ProjectArgs(project, tempPath, "rm", "-s", "-f"), batch...)
+	if output, err := compose.Run(context.Background(), cmdArgs...); err != nil {
+		utils.Logger(utils.ColorRed, "Error removing failed batch: %s", string(output))
+	}
+
+	if snapshot.Empty() {
+		return
+	}
+
+	if _, err := snapshot.Restore(); err != nil {
+		utils.Logger(utils.ColorRed, "Error restoring previous containers: %s", err)
+		return
+	}
+	utils.Logger(utils.ColorYellow, "Previous containers of %s restored", strings.Join(batch, ", "))
+}
+
+// haltRollout records the rolling update as failed and returns its error.
+func haltRollout(updated []string, failed []string) error {
+	var message string
+	if len(updated) == 0 {
+		message = fmt.Sprintf("rolling update halted at %s, no services were updated", strings.Join(failed, ", "))
+	} else {
+		message = fmt.Sprintf("rolling update halted at %s, already updated: %s",
+			strings.Join(failed, ", "), strings.Join(updated, ", "))
+	}
+	utils.Logger(utils.ColorRed, "%s", message)
+	return exitDeployment(history.OutcomeFailed, message)
+}
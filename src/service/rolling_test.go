@@ -0,0 +1,48 @@
+package service
+
+import (
+	"docker-deployment/src/runner"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRollingRunKeepsTheProjectOfTheComposeFile(t *testing.T) {
+	startFakeDaemon(t, &fakeContainer{ID: "0123456789ab", Name: "shop-web-1", Project: "shop", Service: "web", Image: "nginx:1.27", Health: "healthy"})
+
+	dir := filepath.Join(t.TempDir(), "shop")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	t.Setenv("COMPOSE_PROJECT_NAME", "")
+	if err := os.WriteFile("docker-compose.yaml", []byte(testCompose), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	script := composeScript()
+	runner.SetDefault(script)
+	t.Cleanup(func() { runner.SetDefault(runner.Exec{}) })
+
+	err := Start(Config{
+		ComposeFile:     "docker-compose.yaml",
+		Strategy:        StrategyRolling,
+		Timeout:         "10s",
+		StabilityWindow: "1s",
+		PullConcurrency: 1,
+	})
+	if err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+
+	var up string
+	for _, command := range script.Recorded() {
+		if strings.Contains(command.String(), " up ") {
+			up = command.String()
+		}
+	}
+	if !strings.HasPrefix(up, "docker compose -p shop -f _temp/") || !strings.HasSuffix(up, " up -d --no-deps web") {
+		t.Errorf("unexpected up command %q", up)
+	}
+}
@@ -1,30 +1,136 @@
 package service
 
 import (
+	"docker-deployment/src/validation"
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
 )
 
 type HealthCheck struct {
-	Test        []string `yaml:"test"`
-	Interval    string   `yaml:"interval,omitempty"`
-	Retries     int      `yaml:"retries,omitempty"`
-	StartPeriod string   `yaml:"start_period,omitempty"`
-	Timeout     string   `yaml:"timeout,omitempty"`
+	Test        HealthCheckTest `yaml:"test"`
+	Interval    string          `yaml:"interval,omitempty"`
+	Retries     int             `yaml:"retries,omitempty"`
+	StartPeriod string          `yaml:"start_period,omitempty"`
+	Timeout     string          `yaml:"timeout,omitempty"`
+	Disable     bool            `yaml:"disable,omitempty"`
 }
 
 type Service struct {
-	ContainerName string       `yaml:"container_name"`
-	Image         string       `yaml:"image"`
-	Ports         []string     `yaml:"ports,omitempty"`
-	DependsOn     []string     `yaml:"depends_on,omitempty"`
-	HealthCheck   *HealthCheck `yaml:"healthcheck,omitempty"`
-	Volumes       []string     `yaml:"volumes,omitempty"`
+	ContainerName   string          `yaml:"container_name"`
+	Image           string          `yaml:"image"`
+	Build           *Build          `yaml:"build,omitempty"`
+	Command         StringOrList    `yaml:"command,omitempty"`
+	Entrypoint      StringOrList    `yaml:"entrypoint,omitempty"`
+	Environment     ListOrDict      `yaml:"environment,omitempty"`
+	EnvFile         EnvFiles        `yaml:"env_file,omitempty"`
+	Labels          Labels          `yaml:"labels,omitempty"`
+	Ports           []Port          `yaml:"ports,omitempty"`
+	Expose          []string        `yaml:"expose,omitempty"`
+	DependsOn       Dependencies    `yaml:"depends_on,omitempty"`
+	HealthCheck     *HealthCheck    `yaml:"healthcheck,omitempty"`
+	Volumes         []ServiceVolume `yaml:"volumes,omitempty"`
+	Networks        ServiceNetworks `yaml:"networks,omitempty"`
+	NetworkMode     string          `yaml:"network_mode,omitempty"`
+	Restart         string          `yaml:"restart,omitempty"`
+	Privileged      bool            `yaml:"privileged,omitempty"`
+	User            string          `yaml:"user,omitempty"`
+	WorkingDir      string          `yaml:"working_dir,omitempty"`
+	Hostname        string          `yaml:"hostname,omitempty"`
+	CapAdd          []string        `yaml:"cap_add,omitempty"`
+	CapDrop         []string        `yaml:"cap_drop,omitempty"`
+	StopGracePeriod string          `yaml:"stop_grace_period,omitempty"`
+	Profiles        []string        `yaml:"profiles,omitempty"`
+	Deploy          *Deploy         `yaml:"deploy,omitempty"`
+	Secrets         []FileReference `yaml:"secrets,omitempty"`
+	Configs         []FileReference `yaml:"configs,omitempty"`
+	// Deployment holds the x-deployment extension
+	Deployment *DeploymentExtension `yaml:"x-deployment,omitempty"`
+	// Extensions holds the other x- extensions, and the keys this model does not know, as decoded
+	Extensions map[string]any `yaml:",inline"`
 }
 
 type Services struct {
-	Services map[string]Service `yaml:"services"`
+	Name     string                        `yaml:"name,omitempty"`
+	Services map[string]Service            `yaml:"services"`
+	Networks map[string]*NetworkDefinition `yaml:"networks,omitempty"`
+	Volumes  map[string]*VolumeDefinition  `yaml:"volumes,omitempty"`
+	Secrets  map[string]*FileDefinition    `yaml:"secrets,omitempty"`
+	Configs  map[string]*FileDefinition    `yaml:"configs,omitempty"`
+	// Deployment holds the top-level x-deployment extension
+	Deployment *ProjectExtension `yaml:"x-deployment,omitempty"`
+	// Extensions holds the other x- extensions, and the keys this model does not know, as decoded
+	Extensions map[string]any `yaml:",inline"`
+}
+
+// names returns the service names in alphabetical order.
+func (s *Services) names() []string {
+	names := make([]string, 0, len(s.Services))
+	for name := range s.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// activeNames returns the names of the services docker-compose starts, see active, in alphabetical order.
+func (s *Services) activeNames() []string {
+	var names []string
+	for _, name := range s.names() {
+		if s.Services[name].active() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// disabled reports whether the healthcheck turns off the one of the image.
+func (h *HealthCheck) disabled() bool {
+	return h.Disable || len(h.Test) > 0 && h.Test[0] == "NONE"
+}
+
+// active reports whether docker-compose starts the service: it has no profile, or one of its profiles is
+// enabled through COMPOSE_PROFILES.
+func (s Service) active() bool {
+	if len(s.Profiles) == 0 {
+		return true
+	}
+	enabled := activeProfiles()
+	for _, profile := range s.Profiles {
+		if enabled[profile] || enabled["*"] {
+			return true
+		}
+	}
+	return false
+}
+
+// settings returns the healthcheck with its durations parsed.
+func (h *HealthCheck) settings() (*validation.HealthCheck, error) {
+	healthCheck := &validation.HealthCheck{Test: []string(h.Test), Retries: h.Retries}
+
+	durations := []struct {
+		name   string
+		value  string
+		target *time.Duration
+	}{
+		{"interval", h.Interval, &healthCheck.Interval},
+		{"timeout", h.Timeout, &healthCheck.Timeout},
+		{"start_period", h.StartPeriod, &healthCheck.StartPeriod},
+	}
+	for _, duration := range durations {
+		if duration.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(duration.value)
+		if err != nil {
+			return healthCheck, fmt.Errorf("invalid %s %q: %w", duration.name, duration.value, err)
+		}
+		*duration.target = parsed
+	}
+	return healthCheck, nil
 }
 
 func loadServicesFromFile(filePath string) (*Services, error) {
@@ -44,3 +150,102 @@ func loadServicesFromFile(filePath string) (*Services, error) {
 
 	return &services, nil
 }
+
+// writePinnedCompose copies the compose file at source to destination, replacing the image
+// of every service present in images.
+func writePinnedCompose(source string, destination string, images map[string]string) error {
+	for serviceName := range images {
+		if !hasService(source, serviceName) {
+			return fmt.Errorf("service %s not found in %s", serviceName, source)
+		}
+	}
+
+	return rewriteCompose(source, destination, func(serviceName string, serviceNode *yaml.Node) {
+		if image, ok := images[serviceName]; ok {
+			setMappingValue(serviceNode, "image", image)
+		}
+	})
+}
+
+// rewriteCompose copies the compose file at source to destination, calling rewrite for every service node.
+func rewriteCompose(source string, destination string, rewrite func(serviceName string, serviceNode *yaml.Node)) error {
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return fmt.Errorf("error decoding YAML: %w", err)
+	}
+
+	var servicesNode *yaml.Node
+	if len(document.Content) > 0 {
+		servicesNode = mappingValue(document.Content[0], "services")
+	}
+	if servicesNode == nil || servicesNode.Kind != yaml.MappingNode {
+		return fmt.Errorf("no services found in %s", source)
+	}
+
+	for i := 0; i+1 < len(servicesNode.Content); i += 2 {
+		rewrite(servicesNode.Content[i].Value, servicesNode.Content[i+1])
+	}
+
+	rewritten, err := yaml.Marshal(&document)
+	if err != nil {
+		return fmt.Errorf("error encoding YAML: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destination), os.ModePerm); err != nil {
+		return err
+	}
+
+	return os.WriteFile(destination, rewritten, 0644)
+}
+
+func hasService(filePath string, serviceName string) bool {
+	services, err := loadServicesFromFile(filePath)
+	if err != nil {
+		return false
+	}
+	_, ok := services.Services[serviceName]
+	return ok
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(node *yaml.Node, key string, value string) {
+	if existing := mappingValue(node, key); existing != nil {
+		existing.Kind = yaml.ScalarNode
+		existing.Tag = "!!str"
+		existing.Value = value
+		existing.Content = nil
+		return
+	}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+	)
+}
+
+func deleteMappingValue(node *yaml.Node, key string) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const profilesCompose = `services:
+  web:
+    image: nginx:1.27
+    depends_on: [debug]
+  debug:
+    image: ghcr.io/acme/debug:1.0
+    profiles: [debug]
+    volumes: ["/var/run/docker.sock:/var/run/docker.sock"]`
+
+func TestInactiveServicesAreLeftOut(t *testing.T) {
+	t.Setenv("COMPOSE_PROFILES", "")
+	services := parseServices(t, profilesCompose)
+
+	if names := services.activeNames(); !reflect.DeepEqual(names, []string{"web"}) {
+		t.Errorf("activeNames() = %v, want [web]", names)
+	}
+	if registries := imageRegistries(services); !reflect.DeepEqual(registries, []string{dockerHub}) {
+		t.Errorf("imageRegistries() = %v, want [%s]", registries, dockerHub)
+	}
+	if policyServices := services.policyServices(); len(policyServices) != 1 || policyServices[0].Name != "web" {
+		t.Errorf("policyServices() = %+v, want web only", policyServices)
+	}
+
+	composePath := filepath.Join(t.TempDir(), "docker-compose.yaml")
+	if err := os.WriteFile(composePath, []byte(profilesCompose), 0644); err != nil {
+		t.Fatal(err)
+	}
+	options, err := healthOptions(composePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := options.Services["debug"]; ok || len(options.Services) != 1 {
+		t.Errorf("healthOptions() services = %v, want web only", options.Services)
+	}
+}
+
+func TestEnabledProfilesAreKept(t *testing.T) {
+	t.Setenv("COMPOSE_PROFILES", "debug")
+	services := parseServices(t, profilesCompose)
+
+	if names := services.activeNames(); !reflect.DeepEqual(names, []string{"debug", "web"}) {
+		t.Errorf("activeNames() = %v, want [debug web]", names)
+	}
+	if registries := imageRegistries(services); !reflect.DeepEqual(registries, []string{dockerHub, "ghcr.io"}) {
+		t.Errorf("imageRegistries() = %v, want [%s ghcr.io]", registries, dockerHub)
+	}
+}
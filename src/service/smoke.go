@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/runner"
+	"docker-deployment/src/utils"
+	"docker-deployment/src/validation"
+	"errors"
+	"fmt"
+)
+
+// runSmokeTests runs the smoke tests of the compose file against the deployed containers.
+func runSmokeTests(project string, composePath string, containerMap map[string]string) error {
+	services, err := loadServicesFromFile(composePath)
+	if err != nil {
+		return err
+	}
+	tests, err := services.smokeTests()
+	if err != nil || len(tests) == 0 {
+		return err
+	}
+
+	if runner.IsDryRun() {
+		utils.Logger(utils.ColorYellow, "[dry-run] skipping %d smoke tests", len(tests))
+		return nil
+	}
+
+	client, err := engine.Default()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	environment := validation.SmokeEnvironment{
+		Containers: map[string]string{},
+		LogLines:   validationOptions.LogLines,
+	}
+	for _, containerID := range containerMap {
+		container, err := client.ContainerInspect(ctx, containerID)
+		if err != nil {
+			continue
+		}
+		environment.Containers[container.Config.Labels[engine.ComposeServiceLabel]] = containerID
+		if environment.Network == "" {
+			environment.Network = projectNetwork(container, project)
+		}
+	}
+
+	for i := range tests {
+		if tests[i].Container != nil && tests[i].Container.Network != "" {
+			tests[i].Container.Network = resolveNetwork(ctx, client, project, tests[i].Container.Network)
+		}
+	}
+
+	_, err = validation.RunSmokeTests(ctx, tests, environment)
+	return err
+}
+
+// projectNetwork returns the network of the container created by the compose project, preferring
+// its default network.
+func projectNetwork(container *engine.ContainerJSON, project string) string {
+	if _, ok := container.NetworkSettings.Networks[project+"_default"]; ok {
+		return project + "_default"
+	}
+	for name := range container.NetworkSettings.Networks {
+		return name
+	}
+	return ""
+}
+
+// resolveNetwork returns the name of a network as declared in the compose file, which compose
+// prefixes with the project name, or name itself when no such network exists.
+func resolveNetwork(ctx context.Context, client *engine.Client, project string, name string) string {
+	networks, err := client.NetworkList(ctx, map[string][]string{"name": {project + "_" + name}})
+	if err != nil && !errors.Is(err, engine.ErrNotFound) {
+		utils.Logger(utils.ColorYellow, "Error listing networks: %s", err)
+		return name
+	}
+	for _, network := range networks {
+		if network.Name == fmt.Sprintf("%s_%s", project, name) {
+			return network.Name
+		}
+	}
+	return name
+}
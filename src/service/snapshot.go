@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"docker-deployment/src/compose"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const retiredSuffix = "-previous"
+
+// SnapshotContainer is a running container captured before a deployment replaces it.
+type SnapshotContainer struct {
+	Service     string          `json:"service"`
+	Name        string          `json:"name"`
+	ID          string          `json:"id"`
+	Image       string          `json:"image"`
+	ImageID     string          `json:"image_id"`
+	RepoDigests []string        `json:"repo_digests,omitempty"`
+	Project     string          `json:"project,omitempty"`
+	Running     bool            `json:"running"`
+	Retired     bool            `json:"retired"`
+	Config      json.RawMessage `json:"config"`
+}
+
+// Snapshot holds everything needed to restore the state that existed before a deployment.
+type Snapshot struct {
+	Dir             string               `json:"-"`
+	DeploymentID    string               `json:"deployment_id"`
+	PreviousCompose string               `json:"previous_compose,omitempty"`
+	PreviousProject string               `json:"previous_project,omitempty"`
+	Containers      []*SnapshotContainer `json:"containers"`
+}
+
+// CaptureSnapshot records the containers the deployment to project is about to replace: for each service
+// docker-compose starts, the container using its container name, or the containers compose named itself,
+// found by the labels of the project and the service.
+func CaptureSnapshot(deploymentDir string, project string, services *Services) (*Snapshot, error) {
+	snapshot := &Snapshot{Dir: deploymentDir}
+
+	client, err := engine.Default()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, serviceName := range services.activeNames() {
+		svc := services.Services[serviceName]
+		if svc.ContainerName != "" {
+			if _, err := snapshot.capture(serviceName, svc.ContainerName); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		containers, err := client.ContainerList(context.Background(), true, engine.ProjectFilter(project, serviceName))
+		if err != nil {
+			return nil, fmt.Errorf("error listing containers of service %s: %w", serviceName, err)
+		}
+		for _, container := range containers {
+			if _, err := snapshot.capture(serviceName, container.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := snapshot.save(); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// Empty reports whether there is anything to roll back to.
+func (s *Snapshot) Empty() bool {
+	return len(s.Containers) == 0
+}
+
+// Retire stops the container and moves it out of the way, keeping it so it can be restored later.
+func (s *Snapshot) Retire(containerName string, containerID string) error {
+	container := s.find(containerID)
+	if container == nil {
+		var err error
+		if container, err = s.capture("", containerID); err != nil {
+			return err
+		}
+		if container == nil {
+			return fmt.Errorf("container %s (%s) not found", containerName, utils.GetShortId(containerID))
+		}
+	}
+
+	retiredName := container.Name + retiredSuffix
+	shortId := utils.GetShortId(container.ID)
+
+	client, err := engine.Default()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	// A container left behind by an earlier run would block the rename
+	_ = client.ContainerRemove(ctx, retiredName, true)
+
+	if err := client.ContainerStop(ctx, container.ID); err != nil {
+		return fmt.Errorf("failed to stop container %s (%s): %s", container.Name, shortId, err)
+	}
+	if err := client.ContainerRename(ctx, container.ID, retiredName); err != nil {
+		return fmt.Errorf("failed to rename container %s (%s): %s", container.Name, shortId, err)
+	}
+
+	container.Retired = true
+	utils.Logger(utils.ColorYellow, "Container [%s] with id [%s] retired as [%s]", container.Name, shortId, retiredName)
+
+	return s.save()
+}
+
+// Restore brings back the captured containers and returns them by name.
+func (s *Snapshot) Restore() (map[string]string, error) {
+	client, err := engine.Default()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+
+	restored := make(map[string]string)
+	var missing []*SnapshotContainer
+
+	for _, container := range s.Containers {
+		shortId := utils.GetShortId(container.ID)
+
+		if !exists(container.ID) {
+			missing = append(missing, container)
+			continue
+		}
+
+		// Whatever the failed deployment left under the original name has to go first
+		if current, _ := inspectContainer(container.Name); current != nil && current.ID != container.ID {
+			_ = client.ContainerRemove(ctx, current.ID, true)
+		}
+
+		if container.Retired {
+			if err := client.ContainerRename(ctx, container.ID, container.Name); err != nil {
+				return nil, fmt.Errorf("failed to rename container %s (%s): %s", container.Name, shortId, err)
+			}
+		}
+
+		if container.Running {
+			if err := client.ContainerStart(ctx, container.ID); err != nil {
+				return nil, fmt.Errorf("failed to start container %s (%s): %s", container.Name, shortId, err)
+			}
+		}
+
+		container.Retired = false
+		restored[container.Name] = container.ID
+		utils.Logger(utils.ColorYellow, "Container [%s] with id [%s] restored", container.Name, shortId)
+	}
+
+	if len(missing) > 0 {
+		recreated, err := s.restoreFromCompose(missing)
+		if err != nil {
+			return nil, err
+		}
+		for name, id := range recreated {
+			restored[name] = id
+		}
+	}
+
+	return restored, s.save()
+}
+
+// Discard removes the retired containers once the new deployment is known to be good.
+func (s *Snapshot) Discard() {
+	client, err := engine.Default()
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Failed to remove retired containers: %s", err)
+		return
+	}
+
+	for _, container := range s.Containers {
+		if !container.Retired {
+			continue
+		}
+		if err := client.ContainerRemove(context.Background(), container.ID, true); err != nil {
+			utils.Logger(utils.ColorRed, "Failed to remove retired container %s (%s): %s",
+				container.Name+retiredSuffix, utils.GetShortId(container.ID), err)
+			continue
+		}
+		container.Retired = false
+	}
+	_ = s.save()
+}
+
+// restoreFromCompose recreates containers that no longer exist from the previously deployed
+// compose file, pinned to the image ids that were running before.
+func (s *Snapshot) restoreFromCompose(missing []*SnapshotContainer) (map[string]string, error) {
+	if s.PreviousCompose == "" {
+		var names []string
+		for _, container := range missing {
+			names = append(names, container.Name)
+		}
+		return nil, fmt.Errorf("containers %s no longer exist and the previous compose file is not available",
+			strings.Join(names, ", "))
+	}
+
+	images := make(map[string]string)
+	var serviceNames []string
+	for _, container := range missing {
+		if container.Service == "" {
+			return nil, fmt.Errorf("container %s has no compose service label", container.Name)
+		}
+		images[container.Service] = container.ImageID
+		serviceNames = append(serviceNames, container.Service)
+	}
+
+	pinnedPath := filepath.Join(s.Dir, "previous", "docker-compose.pinned.yaml")
+	if err := writePinnedCompose(s.PreviousCompose, pinnedPath, images); err != nil {
+		return nil, err
+	}
+
+	args := []string{"-p", s.PreviousProject, "-f", pinnedPath, "up", "-d", "--no-deps"}
+	args = append(args, serviceNames...)
+	utils.Logger(utils.ColorYellow, "Recreating %s from the previous compose file...", strings.Join(serviceNames, ", "))
+	if output, err := compose.Run(context.Background(), args...); err != nil {
+		return nil, fmt.Errorf("failed to recreate previous containers: %s", strings.TrimSpace(string(output)))
+	}
+
+	restored := make(map[string]string)
+	for _, container := range missing {
+		inspect, err := inspectContainer(container.Name)
+		if err != nil || inspect == nil {
+			return nil, fmt.Errorf("container %s was not recreated", container.Name)
+		}
+		restored[container.Name] = inspect.ID
+	}
+
+	return restored, nil
+}
+
+func (s *Snapshot) capture(serviceName string, nameOrID string) (*SnapshotContainer, error) {
+	inspect, err := inspectContainer(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	if inspect == nil {
+		return nil, nil
+	}
+
+	if serviceName == "" {
+		serviceName = inspect.Config.Labels[engine.ComposeServiceLabel]
+	}
+
+	container := &SnapshotContainer{
+		Service:     serviceName,
+		Name:        inspect.ShortName(),
+		ID:          inspect.ID,
+		Image:       inspect.Config.Image,
+		ImageID:     inspect.Image,
+		RepoDigests: imageRepoDigests(inspect.Image),
+		Project:     inspect.Config.Labels[engine.ComposeProjectLabel],
+		Running:     inspect.State.Running,
+		Config:      inspect.Raw,
+	}
+	s.Containers = append(s.Containers, container)
+
+	if s.DeploymentID == "" && container.Project != "" {
+		s.DeploymentID = container.Project
+		s.PreviousProject = container.Project
+		s.capturePreviousCompose(inspect.Config.Labels[engine.ComposeConfigFilesLabel])
+	}
+
+	utils.Logger(utils.ColorBlue, "Captured container %s (%s) running %s", container.Name,
+		utils.GetShortId(container.ID), container.Image)
+
+	return container, nil
+}
+
+// capturePreviousCompose keeps a copy of the compose file the previous deployment used, when it is still readable.
+func (s *Snapshot) capturePreviousCompose(configFiles string) {
+	source := strings.Split(configFiles, ",")[0]
+	if source == "" {
+		return
+	}
+
+	destination := filepath.Join(s.Dir, "previous", "docker-compose.yaml")
+	if err := os.MkdirAll(filepath.Dir(destination), os.ModePerm); err != nil {
+		return
+	}
+	if err := copyFile(source, destination); err != nil {
+		utils.Logger(utils.ColorYellow, "Previous compose file %s is not available: %s", source, err)
+		return
+	}
+
+	s.PreviousCompose = destination
+}
+
+func (s *Snapshot) find(containerID string) *SnapshotContainer {
+	for _, container := range s.Containers {
+		if strings.HasPrefix(container.ID, containerID) {
+			return container
+		}
+	}
+	return nil
+}
+
+func (s *Snapshot) save() error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.Dir, "snapshot.json"), content, 0644)
+}
+
+func exists(containerID string) bool {
+	inspect, err := inspectContainer(containerID)
+	return err == nil && inspect != nil
+}
+
+// inspectContainer returns nil without error when the container does not exist.
+func inspectContainer(nameOrID string) (*engine.ContainerJSON, error) {
+	client, err := engine.Default()
+	if err != nil {
+		return nil, err
+	}
+
+	inspect, err := client.ContainerInspect(context.Background(), nameOrID)
+	if errors.Is(err, engine.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting container %s: %w", nameOrID, err)
+	}
+
+	return inspect, nil
+}
+
+func imageRepoDigests(imageID string) []string {
+	client, err := engine.Default()
+	if err != nil {
+		return nil
+	}
+
+	image, err := client.ImageInspect(context.Background(), imageID)
+	if err != nil {
+		return nil
+	}
+	return image.RepoDigests
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCaptureSnapshotFindsContainersByLabels(t *testing.T) {
+	startFakeDaemon(t,
+		&fakeContainer{ID: "0123456789ab", Name: "web", Project: "shop", Service: "web", Image: "nginx:1.26"},
+		&fakeContainer{ID: "1123456789ab", Name: "shop-worker-1", Project: "shop", Service: "worker", Image: "worker:1.0"},
+		&fakeContainer{ID: "2123456789ab", Name: "shop-worker-2", Project: "shop", Service: "worker", Image: "worker:1.0"},
+		&fakeContainer{ID: "3123456789ab", Name: "other-worker-1", Project: "other", Service: "worker", Image: "worker:0.9"},
+	)
+	services := parseServices(t, `services:
+  web: {image: nginx:1.27, container_name: web}
+  worker: {image: worker:1.1}`)
+
+	snapshot, err := CaptureSnapshot(t.TempDir(), "shop", services)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var captured []string
+	for _, container := range snapshot.Containers {
+		captured = append(captured, container.Service+"/"+container.Name)
+	}
+	sort.Strings(captured)
+	want := []string{"web/web", "worker/shop-worker-1", "worker/shop-worker-2"}
+	if !reflect.DeepEqual(captured, want) {
+		t.Errorf("captured = %v, want %v", captured, want)
+	}
+	if snapshot.PreviousProject != "shop" {
+		t.Errorf("previous project = %q, want shop", snapshot.PreviousProject)
+	}
+}
+
+func TestRestoreFailsWhenContainersCannotBeRecreated(t *testing.T) {
+	startFakeDaemon(t)
+	snapshot := &Snapshot{Dir: t.TempDir(), Containers: []*SnapshotContainer{
+		{Service: "worker", Name: "shop-worker-1", ID: "1123456789ab", Running: true},
+	}}
+
+	if _, err := snapshot.Restore(); err == nil {
+		t.Error("Restore() reported a removed container without previous compose file as restored")
+	}
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strings"
+)
+
+// ListOrDict is a compose mapping written either as a list of KEY=VALUE or as a mapping, like environment
+// or build args. A key without value is nil.
+type ListOrDict map[string]*string
+
+// UnmarshalYAML decodes both the list and the mapping syntax.
+func (l *ListOrDict) UnmarshalYAML(node *yaml.Node) error {
+	values := ListOrDict{}
+	switch node.Kind {
+	case yaml.SequenceNode:
+		var entries []string
+		if err := node.Decode(&entries); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if key, value, ok := strings.Cut(entry, "="); ok {
+				values[key] = &value
+			} else {
+				values[key] = nil
+			}
+		}
+	case yaml.MappingNode:
+		var entries map[string]any
+		if err := node.Decode(&entries); err != nil {
+			return err
+		}
+		for key, entry := range entries {
+			if entry == nil {
+				values[key] = nil
+				continue
+			}
+			value := fmt.Sprint(entry)
+			values[key] = &value
+		}
+	default:
+		return fmt.Errorf("line %d: expected a list or a mapping", node.Line)
+	}
+	*l = values
+	return nil
+}
+
+// Labels are compose labels, written either as a list of KEY=VALUE or as a mapping.
+type Labels map[string]string
+
+// UnmarshalYAML decodes both the list and the mapping syntax.
+func (l *Labels) UnmarshalYAML(node *yaml.Node) error {
+	var values ListOrDict
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+	*l = make(Labels, len(values))
+	for key, value := range values {
+		if value != nil {
+			(*l)[key] = *value
+		} else {
+			(*l)[key] = ""
+		}
+	}
+	return nil
+}
+
+// StringOrList is a value written either as a single string or as a list of strings, like command.
+type StringOrList []string
+
+// UnmarshalYAML decodes a string as a list of one element.
+func (s *StringOrList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = StringOrList{node.Value}
+		return nil
+	}
+	var values []string
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+	*s = values
+	return nil
+}
+
+// HealthCheckTest is the test of a healthcheck. A string is run by the container shell, like CMD-SHELL.
+type HealthCheckTest []string
+
+// UnmarshalYAML decodes a string as a CMD-SHELL test.
+func (t *HealthCheckTest) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*t = HealthCheckTest{"CMD-SHELL", node.Value}
+		return nil
+	}
+	var values []string
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+	*t = values
+	return nil
+}
+
+// EnvFile is a file the environment of a service is read from.
+type EnvFile struct {
+	Path     string `yaml:"path"`
+	Required *bool  `yaml:"required,omitempty"`
+}
+
+// EnvFiles is env_file, written as a path, a list of paths or a list of files.
+type EnvFiles []EnvFile
+
+// UnmarshalYAML decodes every env_file syntax.
+func (e *EnvFiles) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*e = EnvFiles{{Path: node.Value}}
+		return nil
+	}
+	if node.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: env_file must be a path or a list", node.Line)
+	}
+
+	files := make(EnvFiles, 0, len(node.Content))
+	for _, item := range node.Content {
+		file := EnvFile{Path: item.Value}
+		if item.Kind == yaml.MappingNode {
+			if err := item.Decode(&file); err != nil {
+				return err
+			}
+		}
+		files = append(files, file)
+	}
+	*e = files
+	return nil
+}
+
+// Build is how the image of a service is built, written either as a context path or as a mapping.
+type Build struct {
+	Context    string         `yaml:"context,omitempty"`
+	Dockerfile string         `yaml:"dockerfile,omitempty"`
+	Args       ListOrDict     `yaml:"args,omitempty"`
+	Target     string         `yaml:"target,omitempty"`
+	Labels     Labels         `yaml:"labels,omitempty"`
+	CacheFrom  []string       `yaml:"cache_from,omitempty"`
+	Network    string         `yaml:"network,omitempty"`
+	Platforms  []string       `yaml:"platforms,omitempty"`
+	Extensions map[string]any `yaml:",inline"`
+}
+
+// UnmarshalYAML decodes a string as the build context.
+func (b *Build) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*b = Build{Context: node.Value}
+		return nil
+	}
+	type plain Build
+	return node.Decode((*plain)(b))
+}
+
+// Port is a port of a service, written either as [HOST:][PUBLISHED:]TARGET[/PROTOCOL] or as a mapping.
+type Port struct {
+	Target    string `yaml:"target"`
+	Published string `yaml:"published,omitempty"`
+	HostIP    string `yaml:"host_ip,omitempty"`
+	Protocol  string `yaml:"protocol,omitempty"`
+	Mode      string `yaml:"mode,omitempty"`
+}
+
+// UnmarshalYAML decodes both the short and the long port syntax.
+func (p *Port) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		type plain Port
+		return node.Decode((*plain)(p))
+	}
+
+	value := node.Value
+	port := Port{}
+	if spec, protocol, ok := strings.Cut(value, "/"); ok {
+		value, port.Protocol = spec, protocol
+	}
+	// The host IP may be an IPv6 address in brackets, holding colons of its own
+	if strings.HasPrefix(value, "[") {
+		if end := strings.Index(value, "]:"); end >= 0 {
+			port.HostIP, value = value[1:end], value[end+2:]
+		}
+	}
+	parts := strings.Split(value, ":")
+	switch len(parts) {
+	case 1:
+		port.Target = parts[0]
+	case 2:
+		port.Published, port.Target = parts[0], parts[1]
+	case 3:
+		port.HostIP, port.Published, port.Target = parts[0], parts[1], parts[2]
+	default:
+		return fmt.Errorf("line %d: invalid port %q", node.Line, node.Value)
+	}
+	*p = port
+	return nil
+}
+
+// Volume types of a service volume.
+const (
+	VolumeTypeBind   = "bind"
+	VolumeTypeVolume = "volume"
+	VolumeTypeTmpfs  = "tmpfs"
+)
+
+// ServiceVolume is a volume mounted in a service, written either as [SOURCE:]TARGET[:MODE] or as a mapping.
+type ServiceVolume struct {
+	Type     string `yaml:"type"`
+	Source   string `yaml:"source,omitempty"`
+	Target   string `yaml:"target"`
+	ReadOnly bool   `yaml:"read_only,omitempty"`
+}
+
+// UnmarshalYAML decodes both the short and the long volume syntax.
+func (v *ServiceVolume) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		type plain ServiceVolume
+		return node.Decode((*plain)(v))
+	}
+
+	parts := strings.Split(node.Value, ":")
+	volume := ServiceVolume{Type: VolumeTypeVolume}
+	switch len(parts) {
+	case 1:
+		volume.Target = parts[0]
+	case 2, 3:
+		volume.Source, volume.Target = parts[0], parts[1]
+		if len(parts) == 3 {
+			for _, option := range strings.Split(parts[2], ",") {
+				if option == "ro" {
+					volume.ReadOnly = true
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("line %d: invalid volume %q", node.Line, node.Value)
+	}
+	// Paths are bind mounts, names are named volumes
+	if strings.HasPrefix(volume.Source, "/") || strings.HasPrefix(volume.Source, ".") || strings.HasPrefix(volume.Source, "~") {
+		volume.Type = VolumeTypeBind
+	}
+	*v = volume
+	return nil
+}
+
+// ServiceNetwork is the attachment of a service to a network.
+type ServiceNetwork struct {
+	Aliases     []string `yaml:"aliases,omitempty"`
+	IPv4Address string   `yaml:"ipv4_address,omitempty"`
+	IPv6Address string   `yaml:"ipv6_address,omitempty"`
+	Priority    int      `yaml:"priority,omitempty"`
+}
+
+// ServiceNetworks are the networks of a service, written either as a list of names or as a mapping.
+type ServiceNetworks map[string]*ServiceNetwork
+
+// UnmarshalYAML decodes both the list and the mapping syntax.
+func (n *ServiceNetworks) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		var names []string
+		if err := node.Decode(&names); err != nil {
+			return err
+		}
+		*n = make(ServiceNetworks, len(names))
+		for _, name := range names {
+			(*n)[name] = nil
+		}
+		return nil
+	}
+	var networks map[string]*ServiceNetwork
+	if err := node.Decode(&networks); err != nil {
+		return err
+	}
+	*n = networks
+	return nil
+}
+
+// FileReference grants a service access to a secret or a config, written either as its name or as a mapping.
+type FileReference struct {
+	Source string `yaml:"source"`
+	Target string `yaml:"target,omitempty"`
+	UID    string `yaml:"uid,omitempty"`
+	GID    string `yaml:"gid,omitempty"`
+	Mode   *int   `yaml:"mode,omitempty"`
+}
+
+// UnmarshalYAML decodes a string as the source of the reference.
+func (f *FileReference) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*f = FileReference{Source: node.Value}
+		return nil
+	}
+	type plain FileReference
+	return node.Decode((*plain)(f))
+}
+
+// Deploy holds the deploy section of a service.
+type Deploy struct {
+	Mode          string         `yaml:"mode,omitempty"`
+	Replicas      *int           `yaml:"replicas,omitempty"`
+	Labels        Labels         `yaml:"labels,omitempty"`
+	Resources     Resources      `yaml:"resources,omitempty"`
+	RestartPolicy *RestartPolicy `yaml:"restart_policy,omitempty"`
+}
+
+// Resources are the resource limits and reservations of a service.
+type Resources struct {
+	Limits       *Resource `yaml:"limits,omitempty"`
+	Reservations *Resource `yaml:"reservations,omitempty"`
+}
+
+// Resource is an amount of CPU, memory and processes, e.g. cpus "0.5" and memory "512M".
+type Resource struct {
+	CPUs   string `yaml:"cpus,omitempty"`
+	Memory string `yaml:"memory,omitempty"`
+	Pids   int    `yaml:"pids,omitempty"`
+}
+
+// RestartPolicy is how the containers of a service are restarted when they exit.
+type RestartPolicy struct {
+	Condition   string `yaml:"condition,omitempty"`
+	Delay       string `yaml:"delay,omitempty"`
+	MaxAttempts int    `yaml:"max_attempts,omitempty"`
+	Window      string `yaml:"window,omitempty"`
+}
+
+// External marks a resource created outside of compose. The legacy mapping syntax, {name: ...}, also
+// marks it as external.
+type External bool
+
+// UnmarshalYAML decodes both a boolean and the legacy mapping syntax.
+func (e *External) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		*e = true
+		return nil
+	}
+	var value bool
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	*e = External(value)
+	return nil
+}
+
+// NetworkDefinition is a network of the top-level networks section.
+type NetworkDefinition struct {
+	Name       string            `yaml:"name,omitempty"`
+	Driver     string            `yaml:"driver,omitempty"`
+	DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
+	External   External          `yaml:"external,omitempty"`
+	Internal   bool              `yaml:"internal,omitempty"`
+	Attachable bool              `yaml:"attachable,omitempty"`
+	Labels     Labels            `yaml:"labels,omitempty"`
+	Extensions map[string]any    `yaml:",inline"`
+}
+
+// VolumeDefinition is a volume of the top-level volumes section.
+type VolumeDefinition struct {
+	Name       string            `yaml:"name,omitempty"`
+	Driver     string            `yaml:"driver,omitempty"`
+	DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
+	External   External          `yaml:"external,omitempty"`
+	Labels     Labels            `yaml:"labels,omitempty"`
+	Extensions map[string]any    `yaml:",inline"`
+}
+
+// FileDefinition is a secret or a config of the top-level secrets and configs sections, read from a
+// file, an environment variable or, for configs, inline content.
+type FileDefinition struct {
+	Name        string         `yaml:"name,omitempty"`
+	File        string         `yaml:"file,omitempty"`
+	Environment string         `yaml:"environment,omitempty"`
+	Content     string         `yaml:"content,omitempty"`
+	External    External       `yaml:"external,omitempty"`
+	Labels      Labels         `yaml:"labels,omitempty"`
+	Extensions  map[string]any `yaml:",inline"`
+}
+
+// activeProfiles are the profiles enabled through COMPOSE_PROFILES, like docker-compose does.
+func activeProfiles() map[string]bool {
+	profiles := map[string]bool{}
+	for _, profile := range strings.Split(os.Getenv("COMPOSE_PROFILES"), ",") {
+		if profile = strings.TrimSpace(profile); profile != "" {
+			profiles[profile] = true
+		}
+	}
+	return profiles
+}
@@ -2,25 +2,39 @@ package service
 
 import (
 	"context"
+	"docker-deployment/src/compose"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/history"
 	"docker-deployment/src/logger"
+	"docker-deployment/src/runner"
 	"docker-deployment/src/utils"
 	"docker-deployment/src/validation"
 	"fmt"
 	"github.com/google/uuid"
 	"io"
 	"os"
-	"os/exec"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-func Start(timeoutStr string, dockerComposeFile string, force bool) {
+// validationOptions tunes the health validation of the running deployment.
+var validationOptions validation.Options
+
+// Start deploys the compose file of config with its strategy. The returned error tells why the deployment
+// did not succeed; it has been logged and recorded already.
+func Start(config Config) error {
+	if err := config.validate(); err != nil {
+		utils.Logger(utils.ColorRed, "Invalid configuration: %s", err)
+		return err
+	}
+
 	timeout := utils.DefaultTimeout
-	if timeoutStr != "" {
+	if config.Timeout != "" {
 		var err error
-		timeoutSeconds, err := parseTimeoutToSeconds(timeoutStr)
+		timeoutSeconds, err := parseTimeoutToSeconds(config.Timeout)
 		if err != nil {
 			utils.Logger(utils.ColorRed, "Invalid TIMEOUT format: %s", err)
 			timeout = utils.DefaultTimeout
@@ -29,73 +43,311 @@ func Start(timeoutStr string, dockerComposeFile string, force bool) {
 		}
 	}
 
+	if config.DryRun {
+		enableDryRun()
+	}
+
+	validationOptions.StabilityWindow, _ = config.stabilityWindow()
+	validationOptions.LogLines = config.DiagnosticLogLines
+	pullOptions = PullOptions{Concurrency: config.PullConcurrency, Retries: config.PullRetries}
+
+	credentials, err := config.registryCredentials()
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Invalid registry credentials: %s", err)
+		return err
+	}
+	registryCredentials = credentials
+
+	binary, err := compose.Detect(context.Background(), config.Compose)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error detecting docker compose: %s", err)
+		return err
+	}
+	utils.Logger(utils.ColorBlue, "Using %s", binary)
+	compose.Use(binary)
+
+	if err := checkValidation(config.ComposeFile, timeout); err != nil {
+		utils.Logger(utils.ColorRed, "Invalid health validation settings: %s", err)
+		return err
+	}
+
+	if err := checkPolicy(config); err != nil {
+		utils.Logger(utils.ColorRed, "Deployment rejected by policy: %s", err)
+		return err
+	}
+
 	// Log docker-compose file content
-	err := logger.LogDockerComposeContent(dockerComposeFile)
+	err = logger.LogDockerComposeContent(config.ComposeFile)
 	if err != nil {
 		utils.Logger(utils.ColorRed, "Error logging docker-compose content: %s", err)
-		os.Exit(1)
+		return err
 	}
 
-	composeRun(dockerComposeFile, force, timeout, 0)
+	beginRecord(config)
+
+	switch config.Strategy {
+	case StrategyBlueGreen:
+		return blueGreenRun(config, timeout)
+	case StrategyRolling:
+		return rollingRun(config, timeout)
+	default:
+		return composeRun(config.ComposeFile, config.Force, timeout)
+	}
 }
 
-func composeRun(dockerComposeFile string, force bool, timeout time.Duration, counter int) {
-	_ = Prune()
+// enableDryRun makes every command and Docker Engine call that would change the host only print itself.
+func enableDryRun() {
+	runner.SetDefault(runner.NewDryRun(runner.Default()))
+	if client, err := engine.Default(); err == nil {
+		client.SetDryRun(true)
+	}
+	utils.Logger(utils.ColorYellow, "Dry run: nothing will be changed on %s", dockerHost())
+}
+
+func dockerHost() string {
+	if client, err := engine.Default(); err == nil {
+		return client.Host()
+	}
+	return "the docker host"
+}
 
+// deployment is the working copy of a compose file for a single run.
+type deployment struct {
+	ID          string
+	Dir         string
+	Project     string
+	ComposePath string
+	Services    *Services
+}
+
+// prepareDeployment copies the compose file into a new deployment directory, pulls its images, pins them
+// to their digests and runs its migration. The deployment uses the pinned compose file, in project or,
+// when project is empty, in the project compose resolves for the copy.
+func prepareDeployment(dockerComposeFile string, project string) (*deployment, error) {
 	// Generate a UUID and create the path with it
-	tempSource := uuid.New().String()
-	tempPath := fmt.Sprintf("_temp/%s/docker-compose.yaml", tempSource)
+	deploymentID := uuid.New().String()
+	deploymentDir := fmt.Sprintf("_temp/%s", deploymentID)
+	tempPath := fmt.Sprintf("%s/docker-compose.yaml", deploymentDir)
 
 	// Create the destination directory if it does not exist
-	err := os.MkdirAll(fmt.Sprintf("_temp/%s", tempSource), os.ModePerm)
+	err := os.MkdirAll(deploymentDir, os.ModePerm)
 	if err != nil {
 		utils.Logger(utils.ColorRed, "Error creating directory: %s", err)
-		os.Exit(1)
+		return nil, exitDeployment(history.OutcomeFailed, err.Error())
 	}
 
 	// Copy the docker-compose file to the destination path
 	err = copyFile(dockerComposeFile, tempPath)
 	if err != nil {
 		utils.Logger(utils.ColorRed, "Error copying docker-compose file: %s", err)
-		os.Exit(1)
+		return nil, exitDeployment(history.OutcomeFailed, err.Error())
 	}
-	//
-	//err = Pull(tempPath)
-	//if err != nil {
-	//	os.Exit(1)
-	//}
 
 	// Load services
 	services, err := loadServicesFromFile(tempPath)
 	if err != nil {
-		fmt.Printf("Error loading services: %s\n", err)
-		return
+		utils.Logger(utils.ColorRed, "Error loading services: %s", err)
+		return nil, exitDeployment(history.OutcomeFailed, err.Error())
 	}
 
+	attachDeployment(deploymentID, tempPath)
+
+	// Every compose call and container lookup of the run uses this project
+	if project == "" {
+		project = utils.ComposeProjectName(tempPath)
+	}
+
+	if err := loginRegistries(services, registryCredentials); err != nil {
+		utils.Logger(utils.ColorRed, "Registry login failed, running containers were not touched: %s", err)
+		return nil, exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	// Images are pulled before any container is touched, so a failed pull changes nothing
+	if err := Pull(project, tempPath, services, pullOptions); err != nil {
+		utils.Logger(utils.ColorRed, "Pull failed, running containers were not touched: %s", err)
+		return nil, exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	// The deployment runs the digests just pulled, whatever the tags point to later
+	pinnedPath, pinned, err := pinImages(tempPath, services)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error pinning images: %s", err)
+		return nil, exitDeployment(history.OutcomeFailed, err.Error())
+	}
+	recordPinnedImages(pinned)
+	tempPath = pinnedPath
+	if services, err = loadServicesFromFile(tempPath); err != nil {
+		utils.Logger(utils.ColorRed, "Error loading services: %s", err)
+		return nil, exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	// Migrations run before any container is touched, a failure leaves the running ones as they are
+	if err := runMigration(project, tempPath, services); err != nil {
+		utils.Logger(utils.ColorRed, "Migration failed, running containers were not touched: %s", err)
+		return nil, exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	return &deployment{ID: deploymentID, Dir: deploymentDir, Project: project, ComposePath: tempPath, Services: services}, nil
+}
+
+func composeRun(dockerComposeFile string, force bool, timeout time.Duration) error {
+	_ = Prune()
+
+	current, err := prepareDeployment(dockerComposeFile, "")
+	if err != nil {
+		return err
+	}
+	deploymentDir, project, tempPath, services := current.Dir, current.Project, current.ComposePath, current.Services
+
+	// Capture the containers this deployment is about to replace
+	snapshot, err := CaptureSnapshot(deploymentDir, project, services)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error capturing running containers: %s", err)
+		return exitDeployment(history.OutcomeFailed, err.Error())
+	}
+
+	// The migration service already ran, naming the other services keeps up from running it again
+	if err = composeUp(project, tempPath, force, snapshot, len(services.Services), services.upServices()...); err != nil {
+		utils.Logger(utils.ColorRed, "Error running docker-compose: %s", err)
+		return rollback(snapshot, project, tempPath, timeout)
+	}
+
+	// Get containers, a deployment that started none of its services failed
+	containerMap, err := GetContainers(project)
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Error getting containers: %s", err)
+		return rollback(snapshot, project, tempPath, timeout)
+	}
+
+	if err = validateDeployment(containerMap, project, tempPath, dockerComposeFile, timeout); err != nil {
+		utils.Logger(utils.ColorRed, "Health check error: %s", err)
+		return rollback(snapshot, project, tempPath, timeout)
+	}
+
+	if err = runSmokeTests(project, tempPath, containerMap); err != nil {
+		utils.Logger(utils.ColorRed, "Smoke test error: %s", err)
+		return rollback(snapshot, project, tempPath, timeout)
+	}
+
+	recordImages(containerMap)
+	snapshot.Discard()
+	// Pruning only now keeps the retired containers available for a rollback
+	_ = Prune()
+	finishRecord(history.OutcomeSuccess, "")
+	return nil
+}
+
+// composeUp starts the compose file, retiring containers whose names conflict when force is set.
+// When services are given only those are started, without their dependencies.
+func composeUp(project string, tempPath string, force bool, snapshot *Snapshot, attempts int, services ...string) error {
 	// Prepare docker-compose command with optional --force-recreate
-	cmdArgs := []string{"-f", tempPath, "up", "-d"}
+	cmdArgs := compose.ProjectArgs(project, tempPath, "up", "-d")
 	if force {
 		cmdArgs = append(cmdArgs, "--force-recreate")
 	}
-	utils.Logger(utils.ColorBlue, "Starting docker-compose...")
-	cmd := exec.Command("docker-compose", cmdArgs...)
+	if len(services) > 0 {
+		cmdArgs = append(cmdArgs, "--no-deps")
+		cmdArgs = append(cmdArgs, services...)
+	}
 
-	var output []byte
+	for counter := 0; ; counter++ {
+		utils.Logger(utils.ColorBlue, "Starting docker-compose...")
+		output, err := compose.Run(context.Background(), cmdArgs...)
+		if err == nil {
+			return nil
+		}
 
-	if output, err = cmd.CombinedOutput(); err != nil {
 		utils.Logger(utils.ColorRed, "Error running docker-compose: %s", string(output))
-		if counter < len(services.Services) && force {
-			removeOldContainer(string(output))
-			composeRun(tempPath, force, timeout, counter+1)
-			return
+		if counter >= attempts || !force {
+			return fmt.Errorf("docker-compose up failed: %w", err)
+		}
+		if err := removeOldContainer(string(output), snapshot); err != nil {
+			return err
 		}
-		os.Exit(1)
 	}
+}
 
-	// Get containers
-	containerMap, err := GetContainers(tempPath)
+// healthOptions returns the validation options with the settings of the services the compose file starts.
+func healthOptions(composePath string) (validation.Options, error) {
+	options := validationOptions
+	services, err := loadServicesFromFile(composePath)
 	if err != nil {
-		utils.Logger(utils.ColorRed, "Error getting containers: %s", err)
+		return options, err
+	}
+
+	// A service another one waits to complete successfully is validated as a one-shot job
+	jobs := map[string]bool{}
+	for _, name := range services.activeNames() {
+		service := services.Services[name]
+		if service.isJob() {
+			jobs[name] = true
+		}
+		for name, dependency := range service.DependsOn {
+			if dependency.Condition == ConditionCompleted {
+				jobs[name] = true
+			}
+		}
+	}
+
+	options.Services = map[string]validation.ServiceOptions{}
+	for _, name := range services.activeNames() {
+		service := services.Services[name]
+		serviceOptions := validation.ServiceOptions{DependsOn: service.DependsOn.conditions(), Job: jobs[name]}
+		if service.HealthCheck != nil {
+			if serviceOptions.HealthCheck, err = service.HealthCheck.settings(); err != nil {
+				return options, fmt.Errorf("invalid healthcheck of service %s: %w", name, err)
+			}
+		}
+		if serviceOptions.Probes, err = service.probes(name); err != nil {
+			return options, err
+		}
+		options.Services[name] = serviceOptions
+	}
+	return options, nil
+}
+
+// checkValidation returns an error when the healthchecks, readiness probes or smoke tests of the compose
+// file are invalid, or when timeout is shorter than what the healthcheck of a service can need.
+func checkValidation(composePath string, timeout time.Duration) error {
+	options, err := healthOptions(composePath)
+	if err != nil {
+		return err
+	}
+	services, err := loadServicesFromFile(composePath)
+	if err != nil {
+		return err
+	}
+	if _, err := services.smokeTests(); err != nil {
+		return err
+	}
+	if _, err := services.migration(); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(options.Services))
+	for name := range options.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		healthCheck := options.Services[name].HealthCheck
+		if healthCheck == nil {
+			continue
+		}
+		if needed := healthCheck.Needed(); timeout < needed {
+			return fmt.Errorf("timeout %s is shorter than the %s the healthcheck of service %s can need (%s)",
+				timeout, needed, name, healthCheck.Describe())
+		}
+	}
+	return nil
+}
+
+// validateDeployment runs the health check while following the containers logs.
+func validateDeployment(containerMap map[string]string, project string, tempPath string, dockerComposeFile string, timeout time.Duration) error {
+	if runner.IsDryRun() {
+		utils.Logger(utils.ColorYellow, "[dry-run] skipping health validation of %s", dockerComposeFile)
+		return nil
 	}
 
 	// Context with timeout
@@ -108,12 +360,16 @@ func composeRun(dockerComposeFile string, force bool, timeout time.Duration, cou
 
 	// Run health check in a goroutine
 	go func() {
-		healthCheckDone <- validation.ValidateHealthCheck(ctx, timeout, containerMap, dockerComposeFile)
+		options, err := healthOptions(tempPath)
+		if err == nil {
+			_, err = validation.ValidateHealthCheck(ctx, timeout, containerMap, dockerComposeFile, options)
+		}
+		healthCheckDone <- err
 	}()
 
 	// Run logs retrieval in a goroutine
 	go func() {
-		err := logger.GetPodLogs(ctx, tempPath)
+		err := logger.GetPodLogs(ctx, project)
 		if err != nil {
 			utils.Logger(utils.ColorRed, "Logs retrieval error: %s", err)
 		}
@@ -125,21 +381,54 @@ func composeRun(dockerComposeFile string, force bool, timeout time.Duration, cou
 	case <-logsDone:
 		// Logs retrieval completed
 		// No need to do anything, health check might still be running
-		cancel()          // Cancel health check
-		<-healthCheckDone // Ensure health check completes
+		cancel()                 // Cancel health check
+		return <-healthCheckDone // Ensure health check completes
 
 	case err := <-healthCheckDone:
-		_ = Prune()
 		// Health check completed
-		if err != nil {
-			utils.Logger(utils.ColorRed, "Health check error: %s", err)
-			cancel()
-			<-logsDone
-			os.Exit(1)
-		}
+		cancel()
+		<-logsDone
+		return err
 	}
 }
 
+// rollback restores the containers captured before the deployment and returns the error of the failed deployment.
+func rollback(snapshot *Snapshot, project string, tempPath string, timeout time.Duration) error {
+	if snapshot.Empty() {
+		utils.Logger(utils.ColorRed, "No previous containers to roll back to.")
+		return exitDeployment(history.OutcomeFailed, "no previous containers to roll back to")
+	}
+
+	utils.Logger(utils.ColorYellow, "Rolling back to %s...", snapshot.DeploymentID)
+
+	// Stop whatever the failed deployment started
+	if output, err := compose.Run(context.Background(), compose.ProjectArgs(project, tempPath, "down", "--remove-orphans")...); err != nil {
+		utils.Logger(utils.ColorRed, "Error removing failed deployment: %s", string(output))
+	}
+
+	containerMap, err := snapshot.Restore()
+	if err != nil {
+		utils.Logger(utils.ColorRed, "Rollback to %s failed: %s", snapshot.DeploymentID, err)
+		return exitDeployment(history.OutcomeFailed, fmt.Sprintf("rollback to %s failed: %s", snapshot.DeploymentID, err))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// The restored one-shot containers are already done, the compose file tells which ones they are
+	options, err := healthOptions(tempPath)
+	if err != nil {
+		options = validationOptions
+	}
+	if _, err := validation.ValidateHealthCheck(ctx, timeout, containerMap, snapshot.DeploymentID, options); err != nil {
+		utils.Logger(utils.ColorRed, "Rollback to %s failed health check: %s", snapshot.DeploymentID, err)
+		return exitDeployment(history.OutcomeFailed, fmt.Sprintf("rollback to %s failed health check: %s", snapshot.DeploymentID, err))
+	}
+
+	utils.Logger(utils.ColorYellow, "Deployment failed, rolled back to %s", snapshot.DeploymentID)
+	return exitDeployment(history.OutcomeRolledBack, fmt.Sprintf("rolled back to %s", snapshot.DeploymentID))
+}
+
 // copyFile copies a file from src to dst
 func copyFile(src string, dst string) error {
 	sourceFileStat, err := os.Stat(src)
@@ -181,40 +470,29 @@ func copyFile(src string, dst string) error {
 	return nil
 }
 
-func removeOldContainer(output string) {
+func removeOldContainer(output string, snapshot *Snapshot) error {
 	// Adjusted pattern with non-greedy matching
 	pattern := `Error response from daemon: Conflict. The container name "/([^"]+)" is already in use by container "([0-9a-fA-F]{12,})". You have to remove \(or rename\) that container to be able to reuse that name.`
 	re := regexp.MustCompile(pattern)
 
-	if re.MatchString(output) {
-		// Extract container name and ID from the error message
-		matches := re.FindStringSubmatch(output)
-		if len(matches) == 3 {
-			containerName := matches[1]
-			containerID := matches[2]
+	if !re.MatchString(output) {
+		return fmt.Errorf("failed to remove old container")
+	}
 
-			shortId := utils.GetShortId(containerID)
+	// Extract container name and ID from the error message
+	matches := re.FindStringSubmatch(output)
+	if len(matches) != 3 {
+		return fmt.Errorf("failed to parse error message: %s", output)
+	}
 
-			utils.Logger(utils.ColorYellow, "Trying to remove container: [%s] with id [%s]",
-				containerName, shortId)
+	containerName := matches[1]
+	containerID := matches[2]
 
-			// Remove the container
-			removeCmd := exec.Command("docker", "rm", "-f", containerID)
-			if removeErr := removeCmd.Run(); removeErr != nil {
-				utils.Logger(utils.ColorRed, "Failed to remove container %s: %s", shortId, removeErr)
-				os.Exit(1)
-			}
+	utils.Logger(utils.ColorYellow, "Trying to remove container: [%s] with id [%s]",
+		containerName, utils.GetShortId(containerID))
 
-			utils.Logger(utils.ColorYellow, "Container [%s] with id [%s] removed successful",
-				containerName, shortId)
-		} else {
-			utils.Logger(utils.ColorRed, "Failed to parse error message: %s", output)
-			os.Exit(1)
-		}
-	} else {
-		utils.Logger(utils.ColorRed, "Failed to remove old container.")
-		os.Exit(1)
-	}
+	// Keep the container around so it can be restored if the deployment fails
+	return snapshot.Retire(containerName, containerID)
 }
 
 func parseTimeoutToSeconds(timeoutStr string) (int64, error) {
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"docker-deployment/src/history"
+	"docker-deployment/src/runner"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testCompose = `services:
+  web:
+    image: nginx:1.27
+    healthcheck:
+      test: ["CMD", "true"]
+      interval: 1s
+`
+
+// startDeployment runs Start against the compose file in a scratch directory, with compose answered
+// by script. It returns the error of Start and the compose command lines run.
+func startDeployment(t *testing.T, script *runner.Script) ([]string, error) {
+	t.Helper()
+
+	t.Chdir(t.TempDir())
+	t.Setenv("COMPOSE_PROJECT_NAME", "shop")
+	if err := os.WriteFile("docker-compose.yaml", []byte(testCompose), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner.SetDefault(script)
+	t.Cleanup(func() { runner.SetDefault(runner.Exec{}) })
+
+	err := Start(Config{
+		ComposeFile:     "docker-compose.yaml",
+		Timeout:         "10s",
+		StabilityWindow: "1s",
+		PullConcurrency: 1,
+	})
+
+	var commands []string
+	for _, command := range script.Recorded() {
+		commands = append(commands, command.String())
+	}
+	return commands, err
+}
+
+func composeScript() *runner.Script {
+	return runner.NewScript().
+		Expect("docker compose version", runner.Response{Output: "2.29.1"}).
+		Expect("docker compose -p shop ")
+}
+
+func deploymentOutcome(t *testing.T, err error) string {
+	t.Helper()
+
+	var deploymentErr *deploymentError
+	if !errors.As(err, &deploymentErr) {
+		t.Fatalf("expected a deployment error, got %v", err)
+	}
+	return deploymentErr.Outcome
+}
+
+func TestStartDeploysHealthyContainers(t *testing.T) {
+	startFakeDaemon(t, &fakeContainer{ID: "0123456789ab", Name: "shop-web-1", Project: "shop", Service: "web", Image: "nginx:1.27", Health: "healthy"})
+
+	commands, err := startDeployment(t, composeScript())
+	if err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+
+	var up string
+	for _, command := range commands {
+		if strings.Contains(command, " up ") {
+			up = command
+		}
+		if strings.HasPrefix(command, "docker compose -f") {
+			t.Errorf("compose run without project: %s", command)
+		}
+	}
+	if !strings.HasPrefix(up, "docker compose -p shop -f _temp/") || !strings.HasSuffix(up, "/docker-compose.pinned.yaml up -d") {
+		t.Errorf("unexpected up command %q", up)
+	}
+}
+
+func TestStartFailsWhenNoContainerStarted(t *testing.T) {
+	// Containers of another project must not be mistaken for the deployed ones
+	startFakeDaemon(t, &fakeContainer{ID: "0123456789ab", Name: "other-web-1", Project: "other", Service: "web", Health: "healthy"})
+
+	_, err := startDeployment(t, composeScript())
+	if outcome := deploymentOutcome(t, err); outcome != history.OutcomeFailed {
+		t.Errorf("outcome = %s, want %s", outcome, history.OutcomeFailed)
+	}
+}
+
+func TestStartFailsOnUnhealthyContainer(t *testing.T) {
+	startFakeDaemon(t, &fakeContainer{ID: "0123456789ab", Name: "shop-web-1", Project: "shop", Service: "web", Image: "nginx:1.27", Health: "unhealthy"})
+
+	_, err := startDeployment(t, composeScript())
+	if outcome := deploymentOutcome(t, err); outcome != history.OutcomeFailed {
+		t.Errorf("outcome = %s, want %s", outcome, history.OutcomeFailed)
+	}
+}
+
+func TestStartLeavesContainersAloneWhenPullFails(t *testing.T) {
+	daemon := startFakeDaemon(t)
+	script := runner.NewScript().
+		Expect("docker compose version", runner.Response{Output: "2.29.1"}).
+		Expect("docker compose -p shop ", runner.Response{Output: "manifest unknown", Err: errors.New("exit status 1")})
+
+	commands, err := startDeployment(t, script)
+	if outcome := deploymentOutcome(t, err); outcome != history.OutcomeFailed {
+		t.Errorf("outcome = %s, want %s", outcome, history.OutcomeFailed)
+	}
+
+	for _, command := range commands {
+		if strings.Contains(command, " up ") {
+			t.Errorf("containers started after a failed pull: %s", command)
+		}
+	}
+	for _, request := range daemon.Requests() {
+		if strings.HasPrefix(request, "GET /containers/json") {
+			t.Errorf("containers looked up after a failed pull: %s", request)
+		}
+	}
+}
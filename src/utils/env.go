@@ -2,6 +2,7 @@ package utils
 
 import (
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -20,3 +21,16 @@ func GetBoolEnv(key string, defaultValue bool) bool {
 	Logger(ColorRed, "%s environment variable must be 'true', 'false', '1', or '0'", key)
 	return defaultValue
 }
+
+func GetIntEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	intValue, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		Logger(ColorRed, "%s environment variable must be a number", key)
+		return defaultValue
+	}
+	return intValue
+}
@@ -18,6 +18,21 @@ func displayUsage(required bool) {
 	Logger(ColorBlue, "  DOCKER_COMPOSE_FILE - Path to the docker-compose file")
 	Logger(ColorBlue, "  TIMEOUT - Timeout for the service start (optional), default is 5 minutes")
 	Logger(ColorBlue, "  FORCE - Force restart of containers (optional), default false")
+	Logger(ColorBlue, "  COMPOSE_IMPLEMENTATION - auto, plugin or standalone (optional), default auto")
+	Logger(ColorBlue, "  PLAN_FORMAT - Output of the plan command, text or markdown (optional), default text")
+	Logger(ColorBlue, "  DRY_RUN - Print what would be executed without changing anything (optional), default false")
+	Logger(ColorBlue, "  DEPLOY_STRATEGY - recreate, blue-green or rolling (optional), default recreate")
+	Logger(ColorBlue, "  COMPOSE_PROJECT_NAME - Compose project name, prefix of the blue-green colours (optional), default the top-level name or compose file directory")
+	Logger(ColorBlue, "  ROLLING_BATCH_SIZE - Services updated at once by rolling (optional), default 1")
+	Logger(ColorBlue, "  STABILITY_WINDOW - Seconds a container without healthcheck must keep running (optional), default 15")
+	Logger(ColorBlue, "  DIAGNOSTIC_LOG_LINES - Log lines shown for a container that fails validation (optional), default 30")
+	Logger(ColorBlue, "  PULL_CONCURRENCY - Images pulled at the same time (optional), default 4")
+	Logger(ColorBlue, "  PULL_RETRIES - Extra attempts of a pull failing with a transient error (optional), default 3")
+	Logger(ColorBlue, "  DOCKER_REGISTRY_HOST, DOCKER_REGISTRY_USERNAME, DOCKER_REGISTRY_PASSWORD - Credentials of a registry (optional)")
+	Logger(ColorBlue, "  REGISTRY_CREDENTIALS_FILE - YAML or JSON file with the credentials of several registries (optional)")
+	Logger(ColorBlue, "  REGISTRY_DOCKER_CONFIG - Docker config.json whose credentials are used to log in (optional)")
+	Logger(ColorBlue, "  POLICY_FILE - Policy file setting each rule to off, warn or deny (optional), every rule warns by default")
+	Logger(ColorBlue, "  HISTORY_VOLUME - Volume holding the deployment history (optional), default docker-deployment-history")
 	if required {
 		os.Exit(1)
 	}
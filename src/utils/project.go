@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var invalidProjectChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// ComposeProjectName returns the project name docker compose uses for the compose file: COMPOSE_PROJECT_NAME,
+// then the top-level name of the file, then the name of the directory holding it.
+func ComposeProjectName(dockerComposeFile string) string {
+	if name := DeclaredProjectName(dockerComposeFile); name != "" {
+		return name
+	}
+
+	absolute, err := filepath.Abs(dockerComposeFile)
+	if err != nil {
+		absolute = dockerComposeFile
+	}
+	return NormalizeProjectName(filepath.Base(filepath.Dir(absolute)))
+}
+
+// DeclaredProjectName returns the project name set by COMPOSE_PROJECT_NAME or the top-level name of the compose
+// file, or "" when docker compose names the project after the directory holding the file.
+func DeclaredProjectName(dockerComposeFile string) string {
+	if name := os.Getenv("COMPOSE_PROJECT_NAME"); name != "" {
+		return NormalizeProjectName(name)
+	}
+
+	var file struct {
+		Name string `yaml:"name"`
+	}
+	if content, err := os.ReadFile(dockerComposeFile); err == nil && yaml.Unmarshal(content, &file) == nil && file.Name != "" {
+		return NormalizeProjectName(os.ExpandEnv(file.Name))
+	}
+	return ""
+}
+
+// NormalizeProjectName lowercases the name and drops the characters compose does not accept.
+func NormalizeProjectName(name string) string {
+	name = invalidProjectChars.ReplaceAllString(strings.ToLower(name), "")
+	name = strings.TrimLeft(name, "_-")
+	if name == "" {
+		return "deployment"
+	}
+	return name
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestComposeProjectName(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		content string
+		want    string
+	}{
+		{name: "directory", content: "services: {}\n", want: "myapp-2"},
+		{name: "top-level name", content: "name: Shop\nservices: {}\n", want: "shop"},
+		{name: "environment wins", env: "Billing", content: "name: shop\nservices: {}\n", want: "billing"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("COMPOSE_PROJECT_NAME", test.env)
+
+			dir := filepath.Join(t.TempDir(), "My.App-2")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			file := filepath.Join(dir, "docker-compose.yaml")
+			if err := os.WriteFile(file, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := ComposeProjectName(file); got != test.want {
+				t.Errorf("ComposeProjectName() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package validation
+
+import (
+	"context"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/utils"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLogLines is the number of log lines shown when a container fails.
+	DefaultLogLines = 30
+	// diagnoseTimeout bounds the calls collecting a diagnosis, which run after the validation context is done.
+	diagnoseTimeout = 15 * time.Second
+)
+
+// Diagnosis explains why a container failed validation.
+type Diagnosis struct {
+	Status       string
+	ExitCode     int
+	OOMKilled    bool
+	Error        string
+	RestartCount int
+	Logs         []string
+	// HealthTest is the configured healthcheck test and Probes the results of its latest runs
+	HealthTest []string
+	Probes     []engine.HealthLog
+}
+
+// diagnose collects the state and last log lines of a container.
+func diagnose(client *engine.Client, containerID string, options Options) *Diagnosis {
+	ctx, cancel := context.WithTimeout(context.Background(), diagnoseTimeout)
+	defer cancel()
+
+	container, err := client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil
+	}
+
+	diagnosis := &Diagnosis{
+		Status:       container.State.Status,
+		ExitCode:     container.State.ExitCode,
+		OOMKilled:    container.State.OOMKilled,
+		Error:        container.State.Error,
+		RestartCount: container.RestartCount,
+	}
+	if healthCheck := options.healthCheck(container); healthCheck != nil {
+		diagnosis.HealthTest = healthCheck.Test
+	}
+	if container.State.Health != nil {
+		diagnosis.Probes = container.State.Health.Log
+	}
+
+	logLines := options.LogLines
+	if logLines <= 0 {
+		logLines = DefaultLogLines
+	}
+	if lines, err := client.ContainerLogLines(ctx, containerID, logLines); err == nil {
+		diagnosis.Logs = lines
+	}
+	return diagnosis
+}
+
+// Summary describes the failure in one line, e.g. "exited 137 — OOM killed after 3 restarts".
+func (d *Diagnosis) Summary() string {
+	summary := d.Status
+	if d.Status != "running" && d.Status != "created" {
+		summary = fmt.Sprintf("%s %d", d.Status, d.ExitCode)
+	}
+
+	var causes []string
+	switch {
+	case d.OOMKilled:
+		causes = append(causes, "OOM killed")
+	case d.ExitCode == 137:
+		causes = append(causes, "killed (SIGKILL)")
+	case d.ExitCode == 139:
+		causes = append(causes, "segmentation fault (SIGSEGV)")
+	case d.ExitCode == 143:
+		causes = append(causes, "terminated (SIGTERM)")
+	}
+	if d.RestartCount > 0 {
+		restarts := fmt.Sprintf("after %d restarts", d.RestartCount)
+		if d.RestartCount == 1 {
+			restarts = "after 1 restart"
+		}
+		causes = append(causes, restarts)
+	}
+
+	if len(causes) > 0 {
+		summary += " — " + strings.Join(causes, " ")
+	}
+	if d.Error != "" {
+		summary += ": " + d.Error
+	}
+	return summary
+}
+
+// Print prints the diagnosis block of a container.
+func (d *Diagnosis) Print(name string, containerID string) {
+	utils.Logger(utils.ColorRed, "Diagnosis of container %s (%s): %s", name, utils.GetShortId(containerID), d.Summary())
+	utils.Logger(utils.ColorRed, "  status: %s, exit code: %d, OOM killed: %t, restarts: %d",
+		d.Status, d.ExitCode, d.OOMKilled, d.RestartCount)
+	if d.Error != "" {
+		utils.Logger(utils.ColorRed, "  error: %s", d.Error)
+	}
+	d.printProbes()
+
+	if len(d.Logs) == 0 {
+		utils.Logger(utils.ColorRed, "  no logs")
+		return
+	}
+	utils.Logger(utils.ColorRed, "  last %d log lines:", len(d.Logs))
+	for _, line := range d.Logs {
+		fmt.Printf("    %s\n", line)
+	}
+}
+
+// printProbes prints the healthcheck test and its failing probes, or the latest probe when none failed.
+func (d *Diagnosis) printProbes() {
+	if len(d.HealthTest) == 0 && len(d.Probes) == 0 {
+		return
+	}
+	if len(d.HealthTest) > 0 {
+		utils.Logger(utils.ColorRed, "  healthcheck: %s", strings.Join(d.HealthTest, " "))
+	}
+
+	var probes []engine.HealthLog
+	for _, probe := range d.Probes {
+		if probe.ExitCode != 0 {
+			probes = append(probes, probe)
+		}
+	}
+	if len(probes) == 0 && len(d.Probes) > 0 {
+		probes = d.Probes[len(d.Probes)-1:]
+	}
+
+	for _, probe := range probes {
+		utils.Logger(utils.ColorRed, "  probe %s - %s exited %d: %s", probe.Start.Local().Format("15:04:05"),
+			probe.End.Local().Format("15:04:05"), probe.ExitCode, probeOutput(probe.Output))
+	}
+}
+
+// probeOutput returns the output of a healthcheck probe on a single line.
+func probeOutput(output string) string {
+	output = strings.Join(strings.Fields(output), " ")
+	if output == "" {
+		return "(no output)"
+	}
+	return utils.ShortString(output, 300)
+}
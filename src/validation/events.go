@@ -0,0 +1,142 @@
+package validation
+
+import (
+	"context"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/utils"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// watchedEvents are the container events that change the outcome of a validation.
+var watchedEvents = []string{"health_status", "die", "oom", "restart", "start"}
+
+// eventWatcher follows the docker events of the validated containers, waking their checks up as
+// soon as something happens. Checks fall back to polling when the events stream is unavailable.
+type eventWatcher struct {
+	mutex     sync.Mutex
+	streaming bool
+	since     time.Time
+	wake      map[string]chan struct{}
+	// incidents are the die, oom and restart events received for each container
+	incidents map[string][]engine.Event
+}
+
+// watchEvents subscribes to the events of the containers until ctx is done.
+func watchEvents(ctx context.Context, client *engine.Client, containerIDs []string) *eventWatcher {
+	watcher := &eventWatcher{
+		streaming: true,
+		since:     time.Now(),
+		wake:      map[string]chan struct{}{},
+		incidents: map[string][]engine.Event{},
+	}
+	for _, containerID := range containerIDs {
+		watcher.wake[containerID] = make(chan struct{}, 1)
+	}
+
+	events, errs := client.Events(ctx, watcher.since, map[string][]string{
+		"type":      {"container"},
+		"container": containerIDs,
+		"event":     watchedEvents,
+	})
+
+	go func() {
+		for event := range events {
+			watcher.handle(event)
+		}
+		if err := <-errs; err != nil {
+			utils.Logger(utils.ColorYellow, "Docker events unavailable, polling container status: %s", err)
+			watcher.mutex.Lock()
+			watcher.streaming = false
+			watcher.mutex.Unlock()
+			watcher.wakeAll()
+		}
+	}()
+
+	return watcher
+}
+
+func (w *eventWatcher) handle(event engine.Event) {
+	// The stream replays the events of the second the subscription started in
+	if event.TimeNano != 0 && event.TimeNano < w.since.UnixNano() {
+		return
+	}
+
+	w.mutex.Lock()
+	wake, ok := w.wake[event.Actor.ID]
+	if ok && (event.Action == "die" || event.Action == "oom" || event.Action == "restart") {
+		w.incidents[event.Actor.ID] = append(w.incidents[event.Actor.ID], event)
+	}
+	w.mutex.Unlock()
+
+	if ok {
+		notify(wake)
+	}
+}
+
+// available reports whether the events stream is being followed.
+func (w *eventWatcher) available() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.streaming
+}
+
+// incident returns the first die, oom or restart event received for the container.
+func (w *eventWatcher) incident(containerID string) *engine.Event {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	incidents := w.incidents[containerID]
+	if len(incidents) == 0 {
+		return nil
+	}
+	// An oom event is followed by die, report the cause
+	for _, event := range incidents {
+		if event.Action == "oom" {
+			return &event
+		}
+	}
+	return &incidents[0]
+}
+
+// wait blocks until an event about the container arrives, interval passes or ctx is done.
+// It returns false when ctx is done.
+func (w *eventWatcher) wait(ctx context.Context, containerID string, interval time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-w.wake[containerID]:
+		return true
+	case <-time.After(interval):
+		return true
+	}
+}
+
+func (w *eventWatcher) wakeAll() {
+	for _, wake := range w.wake {
+		notify(wake)
+	}
+}
+
+func notify(wake chan struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// describeIncident describes a die, oom or restart event of a container.
+func describeIncident(event *engine.Event) string {
+	switch event.Action {
+	case "oom":
+		return "was OOM killed"
+	case "restart":
+		return "restarted"
+	default:
+		if exitCode := event.Actor.Attributes["exitCode"]; exitCode != "" {
+			return fmt.Sprintf("died with code %s", exitCode)
+		}
+		return "died"
+	}
+}
@@ -0,0 +1,78 @@
+package validation
+
+import (
+	"fmt"
+	"time"
+)
+
+// Docker defaults of the healthcheck settings left unset.
+const (
+	defaultHealthInterval = 30 * time.Second
+	defaultHealthTimeout  = 30 * time.Second
+	defaultHealthRetries  = 3
+	// healthDeadlineMargin is added to the time a healthcheck needs, covering the daemon scheduling the probes.
+	healthDeadlineMargin = 10 * time.Second
+	minPollInterval      = time.Second
+	// defaultPollInterval is the poll interval of a healthcheck without interval, the one used before the
+	// healthchecks of the compose file were read, rather than the 30s Docker default.
+	defaultPollInterval = 10 * time.Second
+)
+
+// HealthCheck is a container healthcheck and its timing settings.
+type HealthCheck struct {
+	Test        []string
+	Interval    time.Duration
+	Timeout     time.Duration
+	StartPeriod time.Duration
+	Retries     int
+}
+
+func (h *HealthCheck) interval() time.Duration {
+	if h.Interval <= 0 {
+		return defaultHealthInterval
+	}
+	return h.Interval
+}
+
+func (h *HealthCheck) timeout() time.Duration {
+	if h.Timeout <= 0 {
+		return defaultHealthTimeout
+	}
+	return h.Timeout
+}
+
+func (h *HealthCheck) retries() int {
+	if h.Retries <= 0 {
+		return defaultHealthRetries
+	}
+	return h.Retries
+}
+
+// Needed returns how long the healthcheck can take to decide whether the container is healthy:
+// start_period + interval * retries.
+func (h *HealthCheck) Needed() time.Duration {
+	return h.StartPeriod + h.interval()*time.Duration(h.retries())
+}
+
+// Deadline returns how long validation waits for the container to become healthy: the time the
+// healthcheck needs, the timeout of its last probe and a safety margin.
+func (h *HealthCheck) Deadline() time.Duration {
+	return h.Needed() + h.timeout() + healthDeadlineMargin
+}
+
+// PollInterval returns how often the health status is worth inspecting: once per probe, or every
+// defaultPollInterval when the interval is not set.
+func (h *HealthCheck) PollInterval() time.Duration {
+	if h.Interval <= 0 {
+		return defaultPollInterval
+	}
+	if interval := h.interval(); interval > minPollInterval {
+		return interval
+	}
+	return minPollInterval
+}
+
+// Describe explains how the time the healthcheck needs is computed.
+func (h *HealthCheck) Describe() string {
+	return fmt.Sprintf("start_period %s + %d retries × interval %s", h.StartPeriod, h.retries(), h.interval())
+}
@@ -0,0 +1,52 @@
+package validation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthCheckTimings(t *testing.T) {
+	tests := []struct {
+		name         string
+		healthCheck  HealthCheck
+		needed       time.Duration
+		deadline     time.Duration
+		pollInterval time.Duration
+	}{
+		{
+			name:         "docker defaults",
+			healthCheck:  HealthCheck{},
+			needed:       90 * time.Second,
+			deadline:     130 * time.Second,
+			pollInterval: 10 * time.Second,
+		},
+		{
+			name:         "compose settings",
+			healthCheck:  HealthCheck{Interval: 5 * time.Second, Timeout: 2 * time.Second, StartPeriod: 20 * time.Second, Retries: 4},
+			needed:       40 * time.Second,
+			deadline:     52 * time.Second,
+			pollInterval: 5 * time.Second,
+		},
+		{
+			name:         "fast interval",
+			healthCheck:  HealthCheck{Interval: 200 * time.Millisecond, Timeout: time.Second, Retries: 5},
+			needed:       time.Second,
+			deadline:     12 * time.Second,
+			pollInterval: time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.healthCheck.Needed(); got != test.needed {
+				t.Errorf("Needed() = %s, want %s", got, test.needed)
+			}
+			if got := test.healthCheck.Deadline(); got != test.deadline {
+				t.Errorf("Deadline() = %s, want %s", got, test.deadline)
+			}
+			if got := test.healthCheck.PollInterval(); got != test.pollInterval {
+				t.Errorf("PollInterval() = %s, want %s", got, test.pollInterval)
+			}
+		})
+	}
+}
@@ -1,133 +1,583 @@
 package validation
 
 import (
-	"bytes"
 	"context"
+	"docker-deployment/src/engine"
 	"docker-deployment/src/utils"
+	"errors"
 	"fmt"
-	"os"
-	"os/exec"
-	"strings"
+	"sort"
+	"sync"
 	"time"
 )
 
+const (
+	// eventPollInterval is how often a container is inspected while docker events are followed.
+	eventPollInterval = 30 * time.Second
+	// stabilityPollInterval is how often a container is inspected during its stability window
+	// when docker events are unavailable.
+	stabilityPollInterval = time.Second
+	// DefaultStabilityWindow is how long a container without healthcheck must run without restarting.
+	DefaultStabilityWindow = 15 * time.Second
+	// jobLogLines bounds the logs of a completed one-shot container that are printed.
+	jobLogLines = 200
+)
+
+// Options tunes the validation of a deployment.
+type Options struct {
+	// StabilityWindow is how long a container without healthcheck must run without restarting or exiting.
+	StabilityWindow time.Duration
+	// LogLines is the number of log lines shown in the diagnosis of a failed container.
+	LogLines int
+	// Services holds the settings of each compose service, by service name.
+	Services map[string]ServiceOptions
+}
+
+// ServiceOptions are the validation settings of a compose service.
+type ServiceOptions struct {
+	// HealthCheck is the healthcheck configured in the compose file.
+	HealthCheck *HealthCheck
+	// Probes are the readiness probes that must pass besides the healthcheck.
+	Probes []Probe
+	// DependsOn maps the services this one depends on to the condition they must meet before this
+	// one is validated.
+	DependsOn map[string]string
+	// Job marks a one-shot service, which passes when its container exits 0.
+	Job bool
+}
+
+// healthCheck returns the healthcheck of the container, preferring the one from the compose file
+// over the one the container was created with.
+func (o Options) healthCheck(container *engine.ContainerJSON) *HealthCheck {
+	if service, ok := o.Services[container.Config.Labels[engine.ComposeServiceLabel]]; ok && service.HealthCheck != nil {
+		return service.HealthCheck
+	}
+	if config := container.Config.Healthcheck; config != nil {
+		return &HealthCheck{
+			Test:        config.Test,
+			Interval:    config.Interval,
+			Timeout:     config.Timeout,
+			StartPeriod: config.StartPeriod,
+			Retries:     config.Retries,
+		}
+	}
+	return nil
+}
+
+// ValidateHealthCheck validates every container concurrently under one deadline, each one once the
+// containers of the services it depends on passed. The first failure cancels the validation of the other
+// containers and the dependents of a failed container are skipped. The report lists the final state of
+// every container, dependencies first.
 func ValidateHealthCheck(
 	ctx context.Context,
 	timeout time.Duration,
 	containers map[string]string,
 	dockerComposeFile string,
-) error {
-	time.Sleep(10 * time.Second)
-	for name, containerID := range containers {
-		err := validatePodsStatus(ctx, timeout, name, containerID)
-		if err != nil {
-			return err
+	options Options,
+) (*Report, error) {
+	started := time.Now()
+	report := &Report{}
+
+	// Nothing to validate means nothing was started, which is not a healthy deployment
+	if len(containers) == 0 && len(options.Services) > 0 {
+		return report, fmt.Errorf("no containers found for %s", dockerComposeFile)
+	}
+
+	client, err := engine.Default()
+	if err != nil {
+		return report, err
+	}
+
+	ctx, cancelDeadline := context.WithTimeout(ctx, timeout)
+	defer cancelDeadline()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	deadline, _ := ctx.Deadline()
+
+	names := make([]string, 0, len(containers))
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	board := newStatusBoard(names, deadline)
+	boardCtx, stopBoard := context.WithCancel(ctx)
+	go board.run(boardCtx)
+
+	containerIDs := make([]string, 0, len(containers))
+	for _, name := range names {
+		containerIDs = append(containerIDs, containers[name])
+	}
+	events := watchEvents(boardCtx, client, containerIDs)
+
+	services := make([]string, len(names))
+	for i, name := range names {
+		services[i] = serviceOf(ctx, client, containers[name])
+	}
+	graph := newDependencyGraph(services, options)
+
+	var (
+		waitGroup sync.WaitGroup
+		mutex     sync.Mutex
+	)
+	done := make([]chan struct{}, len(names))
+	checks := make([]*containerCheck, len(names))
+	for i, name := range names {
+		done[i] = make(chan struct{})
+		checks[i] = &containerCheck{
+			client:  client,
+			board:   board,
+			events:  events,
+			options: options,
+			name:    name,
+			id:      containers[name],
+			service: services[i],
+			started: make(chan struct{}),
 		}
 	}
+	report.Containers = make([]ContainerResult, len(names))
+	for i, name := range names {
+		waitGroup.Add(1)
+		go func(i int, name string) {
+			defer waitGroup.Done()
+			defer close(done[i])
+			check := checks[i]
+
+			// Dependencies always finish, they stop themselves when the validation is cancelled
+			if len(graph.dependencies[i]) > 0 {
+				board.update(name, "waiting")
+			}
+			var blocked *ContainerResult
+			for _, dependency := range graph.dependencies[i] {
+				if dependency.condition == ConditionStarted {
+					select {
+					case <-checks[dependency.index].started:
+						continue
+					case <-done[dependency.index]:
+					}
+				} else {
+					<-done[dependency.index]
+				}
+
+				result := report.Containers[dependency.index]
+				if !satisfied(dependency.condition, result, checks[dependency.index].hasStarted()) && blocked == nil {
+					blocked = &result
+				}
+			}
+			if blocked != nil {
+				report.Containers[i] = check.blocked(*blocked)
+				return
+			}
+
+			result := check.validate(ctx)
+			report.Containers[i] = result
+
+			if result.Err != nil && result.State != StateCancelled {
+				mutex.Lock()
+				if report.firstFailure == "" {
+					report.firstFailure = name
+					cancel()
+				}
+				mutex.Unlock()
+			}
+		}(i, name)
+	}
+	waitGroup.Wait()
+	stopBoard()
+	graph.sort(report.Containers)
+
+	report.Duration = time.Since(started)
+	report.Print()
+
+	if err := report.Err(); err != nil {
+		return report, err
+	}
 
 	utils.Logger(utils.ColorGreen, "Deploy for %s completed successfully", dockerComposeFile)
-	return nil
+	return report, nil
+}
+
+// containerCheck validates a single container.
+type containerCheck struct {
+	client  *engine.Client
+	board   *statusBoard
+	events  *eventWatcher
+	options Options
+	name    string
+	id      string
+	service string
+	status  string
+	// started is closed once the container is seen running
+	started     chan struct{}
+	startedOnce sync.Once
+	// lastProbe is the start time of the last healthcheck probe printed
+	lastProbe time.Time
+	// pollInterval is how often the health status is inspected when events are unavailable
+	pollInterval time.Duration
 }
 
-func validatePodsStatus(ctx context.Context, timeout time.Duration, name string, containerID string) error {
-	shortContainerID := utils.GetShortId(containerID)
+func (c *containerCheck) validate(ctx context.Context) ContainerResult {
+	started := time.Now()
 
-	// Check if the container has a health check defined
-	healthCheckCmd := exec.Command("docker", "inspect", "--format={{.State.Health.Status}}", containerID)
-	var healthCheckOut bytes.Buffer
-	healthCheckCmd.Stdout = &healthCheckOut
-	healthCheckCmd.Stderr = os.Stderr
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	if err := healthCheckCmd.Run(); err != nil {
-		utils.Logger(utils.ColorRed, "Error checking health status for container %s (%s)", name, shortContainerID)
-		return checkPosIsRunning(ctx, timeout, name, shortContainerID, containerID)
+	// Readiness probes run alongside the status checks, the first failure stops both
+	probes := c.probes()
+	probeResults := make([]ProbeResult, len(probes))
+	var probeFailure error
+	var waitGroup sync.WaitGroup
+	var mutex sync.Mutex
+	for i, probe := range probes {
+		waitGroup.Add(1)
+		go func(i int, probe Probe) {
+			defer waitGroup.Done()
+			probeResults[i] = runProbe(ctx, c.client, c.id, probe)
+			if !probeResults[i].Passed {
+				mutex.Lock()
+				if probeFailure == nil && ctx.Err() == nil {
+					probeFailure = probeResults[i].Err
+					cancel()
+				}
+				mutex.Unlock()
+			}
+		}(i, probe)
 	}
 
-	healthCheckConfig := strings.TrimSpace(healthCheckOut.String())
-	if healthCheckConfig == "" || healthCheckConfig == "<no value>" {
-		// Health check not provided, check if container is running
-		return checkPosIsRunning(ctx, timeout, name, shortContainerID, containerID)
+	state, err := c.check(ctx)
+	if err != nil {
+		cancel()
+	}
+	waitGroup.Wait()
+
+	if probeFailure != nil && (err == nil || state == StateCancelled) {
+		state = StateFailed
+		err = fmt.Errorf("container %s (%s) is not ready: %w", c.name, utils.GetShortId(c.id), probeFailure)
+	}
+	c.board.update(c.name, state)
+
+	result := ContainerResult{
+		Name:     c.name,
+		ID:       c.id,
+		State:    state,
+		Status:   c.status,
+		Err:      err,
+		Duration: time.Since(started),
+		Probes:   probeResults,
+	}
+	if err != nil && state != StateCancelled {
+		result.Diagnosis = diagnose(c.client, c.id, c.options)
+	}
+	return result
+}
+
+// probes returns the readiness probes of the service the container belongs to.
+func (c *containerCheck) probes() []Probe {
+	return c.options.Services[c.service].Probes
+}
+
+// blocked returns the result of a container that was not validated because its dependency did not pass.
+func (c *containerCheck) blocked(dependency ContainerResult) ContainerResult {
+	result := ContainerResult{Name: c.name, ID: c.id, State: StateSkipped}
+	if dependency.State == StateCancelled {
+		result.State = StateCancelled
+		result.Err = fmt.Errorf("validation of container %s (%s) cancelled", c.name, utils.GetShortId(c.id))
 	} else {
-		// Health check is provided, validate health status
-		return checkPosIsHealthy(ctx, name, containerID, shortContainerID)
+		result.Err = fmt.Errorf("container %s (%s) skipped, dependency %s did not pass", c.name, utils.GetShortId(c.id), dependency.Name)
 	}
+	c.board.update(c.name, result.State)
+	return result
 }
 
-func checkPosIsHealthy(checkCtx context.Context, name string, containerID string, shortContainerID string) error {
-	// Context with timeout for each health check operation
-	ctx, cancel := context.WithTimeout(checkCtx, utils.DefaultTimeout)
-	defer cancel()
+// markStarted records that the container was seen running.
+func (c *containerCheck) markStarted() {
+	c.startedOnce.Do(func() { close(c.started) })
+}
 
-	utils.Logger(utils.ColorBlue, "Checking is healthy for container %s (%s)...", name, shortContainerID)
-	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("timeout while waiting for container %s (%s) to become healthy", name, shortContainerID)
-		default:
-			cmd := exec.Command("docker", "inspect", "--format={{.State.Health.Status}}", containerID)
-			var out bytes.Buffer
-			cmd.Stdout = &out
-			cmd.Stderr = os.Stderr
+func (c *containerCheck) hasStarted() bool {
+	select {
+	case <-c.started:
+		return true
+	default:
+		return false
+	}
+}
 
-			if err := cmd.Run(); err != nil {
-				return fmt.Errorf("error inspecting container %s (%s): %s", name, shortContainerID, err)
-			}
+// serviceOf returns the compose service of a container.
+func serviceOf(ctx context.Context, client *engine.Client, containerID string) string {
+	container, err := client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return ""
+	}
+	return container.Config.Labels[engine.ComposeServiceLabel]
+}
 
-			healthStatus := strings.TrimSpace(out.String())
-			utils.Logger(utils.ColorYellow, "Container %s (%s) health status: %s", name, shortContainerID, healthStatus)
-
-			switch healthStatus {
-			case "healthy":
-				utils.Logger(utils.ColorGreen, "Container %s (%s) is healthy.", name, shortContainerID)
-				return nil
-			case "unhealthy":
-				return fmt.Errorf("container %s (%s) is unhealthy", name, shortContainerID)
-			case "starting":
-				// Continue the loop to keep checking
-			default:
-				return fmt.Errorf("unknown health status for container %s (%s): %s", name, shortContainerID, healthStatus)
-			}
-			time.Sleep(10 * time.Second)
+func (c *containerCheck) check(ctx context.Context) (string, error) {
+	shortContainerID := utils.GetShortId(c.id)
+
+	// Check if the container has a health check defined
+	container, err := c.client.ContainerInspect(ctx, c.id)
+	if err != nil {
+		if ctx.Err() != nil {
+			return c.interrupted(ctx, "running")
 		}
+		utils.Logger(utils.ColorRed, "Error checking health status for container %s (%s)", c.name, shortContainerID)
+		return c.checkIsRunning(ctx)
 	}
-}
 
-func checkPosIsRunning(checkCtx context.Context, timeout time.Duration, name string, shortContainerID string, containerID string) error {
-	// Context with timeout for each status check operation
-	ctx, cancel := context.WithTimeout(checkCtx, utils.DefaultTimeout)
+	if c.options.Services[c.service].Job {
+		// One-shot services are expected to exit
+		return c.checkIsCompleted(ctx)
+	}
+
+	if container.State.Health == nil || container.State.Health.Status == "" {
+		// Health check not provided, check if container is running
+		return c.checkIsRunning(ctx)
+	}
+
+	// Health check is provided, validate health status within the time its settings need
+	healthCheck := c.options.healthCheck(container)
+	if healthCheck == nil {
+		healthCheck = &HealthCheck{}
+	}
+	c.pollInterval = healthCheck.PollInterval()
+
+	healthCtx, cancel := context.WithTimeout(ctx, healthCheck.Deadline())
 	defer cancel()
+	return c.checkIsHealthy(healthCtx)
+}
+
+func (c *containerCheck) checkIsHealthy(ctx context.Context) (string, error) {
+	shortContainerID := utils.GetShortId(c.id)
 
-	utils.Logger(utils.ColorBlue, "Checking running status for container %s (%s)...", name, shortContainerID)
+	utils.Logger(utils.ColorBlue, "Checking is healthy for container %s (%s)...", c.name, shortContainerID)
 	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("timeout while waiting for container %s (%s) to become running", name, shortContainerID)
+		container, err := c.client.ContainerInspect(ctx, c.id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return c.interrupted(ctx, "healthy")
+			}
+			return StateFailed, fmt.Errorf("error inspecting container %s (%s): %s", c.name, shortContainerID, err)
+		}
+
+		// A container that stopped or restarts never becomes healthy
+		if event := c.events.incident(c.id); event != nil {
+			c.observe(container.State.Status)
+			return StateFailed, fmt.Errorf("container %s (%s) %s", c.name, shortContainerID, describeIncident(event))
+		}
+		switch container.State.Status {
+		case "exited", "dead":
+			c.observe(container.State.Status)
+			return StateFailed, fmt.Errorf("container %s (%s) %s with code %d", c.name, shortContainerID,
+				container.State.Status, container.State.ExitCode)
+		case "restarting":
+			c.observe(container.State.Status)
+			return StateFailed, fmt.Errorf("container %s (%s) restarted (restart count %d)", c.name, shortContainerID, container.RestartCount)
+		}
+
+		var healthStatus string
+		if container.State.Health != nil {
+			healthStatus = container.State.Health.Status
+		}
+		c.observe(healthStatus)
+
+		switch healthStatus {
+		case "healthy":
+			utils.Logger(utils.ColorGreen, "Container %s (%s) is healthy.", c.name, shortContainerID)
+			return StateHealthy, nil
+		case "unhealthy":
+			return StateUnhealthy, fmt.Errorf("container %s (%s) is unhealthy", c.name, shortContainerID)
+		case "starting":
+			c.printLatestProbe(container.State.Health)
 		default:
-			cmd := exec.Command("docker", "inspect", "--format={{.State.Status}}", containerID)
-			var out bytes.Buffer
-			cmd.Stdout = &out
-			cmd.Stderr = os.Stderr
+			return StateFailed, fmt.Errorf("unknown health status for container %s (%s): %s", c.name, shortContainerID, healthStatus)
+		}
+
+		interval := c.pollInterval
+		if c.events.available() {
+			interval = eventPollInterval
+		}
+		if !c.wait(ctx, interval) {
+			return c.interrupted(ctx, "healthy")
+		}
+	}
+}
+
+// checkIsRunning waits for the container to run, then watches it for the stability window. It fails as
+// soon as the container restarts, exits or is OOM killed. Restarts are counted from the first inspection,
+// as compose may keep a container that restarted before, and the window starts with the validation at the
+// earliest, so a container running for hours is still watched for the whole window.
+func (c *containerCheck) checkIsRunning(ctx context.Context) (string, error) {
+	shortContainerID := utils.GetShortId(c.id)
+	window := c.options.StabilityWindow
+	if window <= 0 {
+		window = DefaultStabilityWindow
+	}
+
+	utils.Logger(utils.ColorBlue, "Checking container %s (%s) keeps running for %s...", c.name, shortContainerID, window)
 
-			if err := cmd.Run(); err != nil {
-				return fmt.Errorf("error inspecting container %s (%s): %s", name, shortContainerID, err)
+	began := time.Now()
+	var startedAt, windowStart time.Time
+	restarts := -1
+	for {
+		container, err := c.client.ContainerInspect(ctx, c.id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return c.interrupted(ctx, "running")
 			}
+			return StateFailed, fmt.Errorf("error inspecting container %s (%s): %s", c.name, shortContainerID, err)
+		}
+
+		state := container.State
+		c.observe(state.Status)
+		if restarts < 0 {
+			restarts = container.RestartCount
+		}
 
-			status := strings.TrimSpace(out.String())
-			utils.Logger(utils.ColorYellow, "Container %s (%s) status: %s", name, shortContainerID, status)
+		// Restarts between two inspections are only seen in the events
+		if event := c.events.incident(c.id); event != nil {
+			return StateFailed, fmt.Errorf("container %s (%s) %s", c.name, shortContainerID, describeIncident(event))
+		}
 
-			switch status {
-			case "running":
-				time.Sleep(timeout)
+		switch {
+		case state.OOMKilled:
+			return StateFailed, fmt.Errorf("container %s (%s) was OOM killed", c.name, shortContainerID)
+		case container.RestartCount > restarts || state.Restarting:
+			return StateFailed, fmt.Errorf("container %s (%s) restarted (restart count %d)", c.name, shortContainerID, container.RestartCount)
+		case !startedAt.IsZero() && !state.StartedAt.Equal(startedAt):
+			return StateFailed, fmt.Errorf("container %s (%s) restarted at %s", c.name, shortContainerID,
+				state.StartedAt.Local().Format("15:04:05"))
+		case state.Status == "exited" || state.Status == "dead":
+			return StateFailed, fmt.Errorf("container %s (%s) %s with code %d", c.name, shortContainerID, state.Status, state.ExitCode)
+		case state.Status == "running":
+			if startedAt.IsZero() {
+				startedAt, windowStart = state.StartedAt, state.StartedAt
+				if windowStart.Before(began) {
+					windowStart = began
+				}
+			}
+			if time.Since(windowStart) >= window {
 				utils.Logger(utils.ColorYellow, "Note: It is always a good idea to use container health check "+
 					"configuration to monitor container health properly. See more in: https://docs.docker.com/reference/dockerfile/#healthcheck")
-				utils.Logger(utils.ColorGreen, "Container %s (%s) is running.", name, shortContainerID)
-				return nil
-			case "created", "restarting":
-				// Continue the loop to keep checking
-			default:
-				return fmt.Errorf("unknown status for container %s (%s): %s", name, shortContainerID, status)
+				utils.Logger(utils.ColorGreen, "Container %s (%s) is running.", c.name, shortContainerID)
+				return StateRunning, nil
+			}
+		case state.Status == "created":
+			// Continue the loop to keep checking
+		default:
+			return StateFailed, fmt.Errorf("unknown status for container %s (%s): %s", c.name, shortContainerID, state.Status)
+		}
+
+		// With events, the next inspection is when the window ends unless something happens first
+		interval := stabilityPollInterval
+		if c.events.available() && !windowStart.IsZero() {
+			interval = window - time.Since(windowStart)
+		} else if c.events.available() {
+			interval = eventPollInterval
+		}
+		if !c.wait(ctx, interval) {
+			return c.interrupted(ctx, "running")
+		}
+	}
+}
+
+// checkIsCompleted waits for a one-shot container to exit, which it must do with code 0 and without
+// restarting after the first inspection.
+func (c *containerCheck) checkIsCompleted(ctx context.Context) (string, error) {
+	shortContainerID := utils.GetShortId(c.id)
+
+	utils.Logger(utils.ColorBlue, "Waiting for container %s (%s) to complete...", c.name, shortContainerID)
+	restarts := -1
+	for {
+		container, err := c.client.ContainerInspect(ctx, c.id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return c.interrupted(ctx, "completed")
 			}
-			time.Sleep(10 * time.Second)
+			return StateFailed, fmt.Errorf("error inspecting container %s (%s): %s", c.name, shortContainerID, err)
+		}
+
+		state := container.State
+		c.observe(state.Status)
+		if restarts < 0 {
+			restarts = container.RestartCount
+		}
+
+		switch {
+		case state.OOMKilled:
+			return StateFailed, fmt.Errorf("container %s (%s) was OOM killed", c.name, shortContainerID)
+		case state.Status == "exited" && state.ExitCode == 0:
+			utils.Logger(utils.ColorGreen, "Container %s (%s) completed successfully.", c.name, shortContainerID)
+			c.printLogs()
+			return StateCompleted, nil
+		case state.Status == "exited" || state.Status == "dead":
+			return StateFailed, fmt.Errorf("container %s (%s) %s with code %d", c.name, shortContainerID, state.Status, state.ExitCode)
+		case container.RestartCount > restarts || state.Restarting:
+			return StateFailed, fmt.Errorf("container %s (%s) restarted (restart count %d)", c.name, shortContainerID, container.RestartCount)
+		}
+
+		interval := stabilityPollInterval
+		if c.events.available() {
+			interval = eventPollInterval
+		}
+		if !c.wait(ctx, interval) {
+			return c.interrupted(ctx, "completed")
 		}
 	}
 }
+
+// printLogs prints the logs of a completed one-shot container. The logs of a failed one are part of
+// its diagnosis.
+func (c *containerCheck) printLogs() {
+	lines, err := c.client.ContainerLogLines(context.Background(), c.id, jobLogLines)
+	if err != nil || len(lines) == 0 {
+		return
+	}
+	utils.Logger(utils.ColorBlue, "Logs of container %s (%s):", c.name, utils.GetShortId(c.id))
+	for _, line := range lines {
+		fmt.Printf("    %s\n", line)
+	}
+}
+
+// printLatestProbe prints the output of the latest healthcheck probe when it was not printed yet.
+func (c *containerCheck) printLatestProbe(health *engine.Health) {
+	if len(health.Log) == 0 {
+		return
+	}
+	probe := health.Log[len(health.Log)-1]
+	if !probe.Start.After(c.lastProbe) {
+		return
+	}
+	c.lastProbe = probe.Start
+	utils.Logger(utils.ColorYellow, "Container %s (%s) healthcheck probe exited %d: %s", c.name, utils.GetShortId(c.id),
+		probe.ExitCode, probeOutput(probe.Output))
+}
+
+// observe records the status docker reports for the container.
+func (c *containerCheck) observe(status string) {
+	if status != c.status {
+		utils.Logger(utils.ColorYellow, "Container %s (%s) status: %s", c.name, utils.GetShortId(c.id), status)
+	}
+	c.status = status
+	c.board.update(c.name, status)
+	if status != "" && status != "created" {
+		c.markStarted()
+	}
+}
+
+// wait sleeps until the next inspection, or until an event about the container arrives. It returns
+// false when the validation is over.
+func (c *containerCheck) wait(ctx context.Context, interval time.Duration) bool {
+	return c.events.wait(ctx, c.id, interval)
+}
+
+// interrupted returns the state of a container whose validation stopped before it reached the
+// expected status: a timeout when the deadline passed, cancelled when another container failed.
+func (c *containerCheck) interrupted(ctx context.Context, expected string) (string, error) {
+	shortContainerID := utils.GetShortId(c.id)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return StateTimeout, fmt.Errorf("timeout while waiting for container %s (%s) to become %s", c.name, shortContainerID, expected)
+	}
+	return StateCancelled, fmt.Errorf("validation of container %s (%s) cancelled", c.name, shortContainerID)
+}
@@ -0,0 +1,212 @@
+package validation
+
+import (
+	"context"
+	"docker-deployment/src/engine"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testContainerID = "0123456789abcdef"
+
+// inspections answers the inspections of a container with states, one per call, repeating the last one.
+type inspections struct {
+	mutex  sync.Mutex
+	states []engine.ContainerJSON
+	calls  int
+}
+
+func (i *inspections) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/containers/"+testContainerID+"/json" {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "no such object: " + r.URL.Path})
+		return
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	state := i.states[min(i.calls, len(i.states)-1)]
+	i.calls++
+	_ = json.NewEncoder(w).Encode(state)
+}
+
+// newTestCheck returns a check of the container answered by states. With streaming, the check waits as if
+// docker events were followed, otherwise it polls.
+func newTestCheck(t *testing.T, window time.Duration, streaming bool, states ...engine.ContainerJSON) *containerCheck {
+	t.Helper()
+
+	server := httptest.NewServer(http.StripPrefix("/"+engine.APIVersion, &inspections{states: states}))
+	t.Cleanup(server.Close)
+	client, err := engine.New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &containerCheck{
+		client: client,
+		board:  newStatusBoard([]string{"web"}, time.Now().Add(time.Minute)),
+		events: &eventWatcher{
+			streaming: streaming,
+			wake:      map[string]chan struct{}{testContainerID: make(chan struct{}, 1)},
+			incidents: map[string][]engine.Event{},
+		},
+		options: Options{StabilityWindow: window},
+		name:    "web",
+		id:      testContainerID,
+		started: make(chan struct{}),
+	}
+}
+
+func containerState(status string, restarts int, startedAt time.Time) engine.ContainerJSON {
+	return engine.ContainerJSON{
+		ID:           testContainerID,
+		RestartCount: restarts,
+		State:        engine.ContainerState{Status: status, Running: status == "running", StartedAt: startedAt},
+	}
+}
+
+func TestCheckIsRunning(t *testing.T) {
+	const window = 300 * time.Millisecond
+	longAgo := time.Now().Add(-3 * time.Hour)
+	restarting := containerState("restarting", 3, longAgo)
+	restarting.State.Restarting = true
+	oomKilled := containerState("exited", 0, longAgo)
+	oomKilled.State.OOMKilled = true
+
+	tests := []struct {
+		name   string
+		states []engine.ContainerJSON
+		want   string
+		err    string
+	}{
+		{
+			name:   "restarted before the validation",
+			states: []engine.ContainerJSON{containerState("running", 3, longAgo)},
+			want:   StateRunning,
+		},
+		{
+			name:   "restart count increases",
+			states: []engine.ContainerJSON{containerState("running", 3, longAgo), containerState("running", 4, longAgo)},
+			want:   StateFailed,
+			err:    "restarted (restart count 4)",
+		},
+		{
+			name:   "started again",
+			states: []engine.ContainerJSON{containerState("running", 0, longAgo), containerState("running", 0, time.Now())},
+			want:   StateFailed,
+			err:    "restarted at",
+		},
+		{
+			name:   "restarting",
+			states: []engine.ContainerJSON{restarting},
+			want:   StateFailed,
+			err:    "restarted (restart count 3)",
+		},
+		{
+			name:   "exited",
+			states: []engine.ContainerJSON{containerState("running", 0, longAgo), containerState("exited", 0, longAgo)},
+			want:   StateFailed,
+			err:    "exited with code 0",
+		},
+		{
+			name:   "OOM killed",
+			states: []engine.ContainerJSON{oomKilled},
+			want:   StateFailed,
+			err:    "was OOM killed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := newTestCheck(t, window, true, test.states...)
+
+			began := time.Now()
+			state, err := check.checkIsRunning(context.Background())
+			if state != test.want {
+				t.Fatalf("state = %s (%v), want %s", state, err, test.want)
+			}
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("checkIsRunning() = %v", err)
+				}
+				// A container running for hours is still watched for the whole window
+				if elapsed := time.Since(began); elapsed < window {
+					t.Errorf("passed after %s, before the %s window", elapsed, window)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("error = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestCheckIsCompleted(t *testing.T) {
+	longAgo := time.Now().Add(-3 * time.Hour)
+	failed := containerState("exited", 2, longAgo)
+	failed.State.ExitCode = 1
+	restarting := containerState("restarting", 2, longAgo)
+	restarting.State.Restarting = true
+
+	tests := []struct {
+		name   string
+		states []engine.ContainerJSON
+		want   string
+		err    string
+	}{
+		{
+			name:   "completed",
+			states: []engine.ContainerJSON{containerState("exited", 0, longAgo)},
+			want:   StateCompleted,
+		},
+		{
+			name:   "restarted before the validation",
+			states: []engine.ContainerJSON{containerState("running", 2, longAgo), containerState("exited", 2, longAgo)},
+			want:   StateCompleted,
+		},
+		{
+			name:   "restart count increases",
+			states: []engine.ContainerJSON{containerState("running", 2, longAgo), containerState("running", 3, longAgo)},
+			want:   StateFailed,
+			err:    "restarted (restart count 3)",
+		},
+		{
+			name:   "restarting",
+			states: []engine.ContainerJSON{restarting},
+			want:   StateFailed,
+			err:    "restarted (restart count 2)",
+		},
+		{
+			name:   "exit code",
+			states: []engine.ContainerJSON{failed},
+			want:   StateFailed,
+			err:    "exited with code 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := newTestCheck(t, 0, false, test.states...)
+
+			state, err := check.checkIsCompleted(context.Background())
+			if state != test.want {
+				t.Fatalf("state = %s (%v), want %s", state, err, test.want)
+			}
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("checkIsCompleted() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("error = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package validation
+
+import (
+	"docker-deployment/src/utils"
+	"sort"
+)
+
+// Conditions a container waits for on the services it depends on, named after the compose
+// depends_on conditions. An empty condition waits for the dependency to pass validation.
+const (
+	ConditionStarted   = "service_started"
+	ConditionHealthy   = "service_healthy"
+	ConditionCompleted = "service_completed_successfully"
+)
+
+// dependencyEdge is a container the validation of another container waits for.
+type dependencyEdge struct {
+	index     int
+	condition string
+}
+
+// dependencyGraph links every validated container to the containers of the services it depends on.
+type dependencyGraph struct {
+	dependencies [][]dependencyEdge
+	// depth is the length of the longest dependency chain below each container
+	depth []int
+}
+
+// newDependencyGraph builds the graph from the service of each container and the depends_on of the
+// services. Dependencies are ignored when they form a cycle.
+func newDependencyGraph(services []string, options Options) *dependencyGraph {
+	byService := map[string][]int{}
+	for i, service := range services {
+		byService[service] = append(byService[service], i)
+	}
+
+	graph := &dependencyGraph{dependencies: make([][]dependencyEdge, len(services)), depth: make([]int, len(services))}
+	for i, service := range services {
+		for dependency, condition := range options.Services[service].DependsOn {
+			for _, index := range byService[dependency] {
+				graph.dependencies[i] = append(graph.dependencies[i], dependencyEdge{index: index, condition: condition})
+			}
+		}
+		sort.Slice(graph.dependencies[i], func(a, b int) bool {
+			return graph.dependencies[i][a].index < graph.dependencies[i][b].index
+		})
+	}
+
+	visiting := make([]bool, len(services))
+	visited := make([]bool, len(services))
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		if visited[i] {
+			return true
+		}
+		if visiting[i] {
+			return false
+		}
+		visiting[i] = true
+		for _, dependency := range graph.dependencies[i] {
+			if !visit(dependency.index) {
+				return false
+			}
+			if graph.depth[dependency.index]+1 > graph.depth[i] {
+				graph.depth[i] = graph.depth[dependency.index] + 1
+			}
+		}
+		visiting[i] = false
+		visited[i] = true
+		return true
+	}
+
+	for i := range services {
+		if !visit(i) {
+			utils.Logger(utils.ColorYellow, "Circular depends_on between services, validating them without order")
+			return &dependencyGraph{dependencies: make([][]dependencyEdge, len(services)), depth: make([]int, len(services))}
+		}
+	}
+	return graph
+}
+
+// satisfied reports whether the result of a dependency meets the condition of its dependent.
+func satisfied(condition string, result ContainerResult, started bool) bool {
+	switch condition {
+	case ConditionStarted:
+		return started
+	case ConditionCompleted:
+		return result.State == StateCompleted
+	default:
+		return result.Succeeded()
+	}
+}
+
+// sort orders the results so that dependencies come before their dependents.
+func (g *dependencyGraph) sort(results []ContainerResult) {
+	depth := map[string]int{}
+	for i, result := range results {
+		depth[result.ID] = g.depth[i]
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return depth[results[i].ID] < depth[results[j].ID]
+	})
+}
@@ -0,0 +1,30 @@
+package validation
+
+import "testing"
+
+func TestSatisfied(t *testing.T) {
+	tests := []struct {
+		condition string
+		state     string
+		started   bool
+		want      bool
+	}{
+		{ConditionStarted, StateFailed, true, true},
+		{ConditionStarted, "", false, false},
+		{ConditionHealthy, StateHealthy, true, true},
+		{ConditionHealthy, StateRunning, true, true},
+		{ConditionHealthy, StateUnhealthy, true, false},
+		{ConditionHealthy, StateSkipped, false, false},
+		{"", StateCompleted, true, true},
+		{"", StateTimeout, true, false},
+		{ConditionCompleted, StateCompleted, true, true},
+		{ConditionCompleted, StateRunning, true, false},
+		{ConditionCompleted, StateFailed, true, false},
+	}
+
+	for _, test := range tests {
+		if got := satisfied(test.condition, ContainerResult{State: test.state}, test.started); got != test.want {
+			t.Errorf("satisfied(%q, %s, %t) = %t, want %t", test.condition, test.state, test.started, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package validation
+
+import (
+	"context"
+	"docker-deployment/src/engine"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Readiness probe kinds.
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+	ProbeExec = "exec"
+)
+
+const (
+	// DefaultProbeTimeout is how long a probe is retried before it fails.
+	DefaultProbeTimeout = 60 * time.Second
+	// DefaultProbeInterval is the pause between two attempts of a probe.
+	DefaultProbeInterval = 2 * time.Second
+	// probeAttemptTimeout bounds a single attempt of a probe.
+	probeAttemptTimeout = 5 * time.Second
+	// maxProbeBody is the part of an HTTP response body matched against the expected body.
+	maxProbeBody = 64 * 1024
+)
+
+// Probe is a readiness probe run against a container after it starts.
+type Probe struct {
+	Kind string
+	// URL is requested by HTTP probes; when empty, Scheme, Host, Port and Path build it.
+	URL    string
+	Scheme string
+	// Host defaults to the docker host; Port is the container port, resolved to its published host port.
+	Host string
+	Port int
+	Path string
+	// Status is the expected HTTP status, any 2xx or 3xx when zero; Body must match the response body.
+	Status int
+	Body   *regexp.Regexp
+	// Address is dialled by TCP probes; when empty, Host and Port build it.
+	Address string
+	// Command is run in the container by exec probes.
+	Command  []string
+	Timeout  time.Duration
+	Interval time.Duration
+}
+
+// String describes the probe, e.g. "http :8080/health".
+func (p Probe) String() string {
+	switch p.Kind {
+	case ProbeHTTP:
+		if p.URL != "" {
+			return "http " + p.URL
+		}
+		return fmt.Sprintf("http :%d%s", p.Port, p.Path)
+	case ProbeTCP:
+		if p.Address != "" {
+			return "tcp " + p.Address
+		}
+		return fmt.Sprintf("tcp :%d", p.Port)
+	default:
+		return "exec " + strings.Join(p.Command, " ")
+	}
+}
+
+// ProbeResult is the outcome of a readiness probe.
+type ProbeResult struct {
+	Probe    string
+	Passed   bool
+	Attempts int
+	Duration time.Duration
+	Err      error
+}
+
+// runProbe retries the probe until it passes, its timeout passes or ctx is done.
+func runProbe(ctx context.Context, client *engine.Client, containerID string, probe Probe) ProbeResult {
+	timeout := probe.Timeout
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	interval := probe.Interval
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	result := ProbeResult{Probe: probe.String()}
+	for {
+		result.Attempts++
+		result.Err = attemptProbe(ctx, client, containerID, probe)
+		if result.Err == nil {
+			result.Passed = true
+			result.Duration = time.Since(started)
+			return result
+		}
+
+		select {
+		case <-ctx.Done():
+			result.Duration = time.Since(started)
+			if errors.Is(ctx.Err(), context.Canceled) {
+				result.Err = fmt.Errorf("probe %s cancelled: %w", probe, result.Err)
+			} else {
+				result.Err = fmt.Errorf("probe %s did not pass within %s: %w", probe, timeout, result.Err)
+			}
+			return result
+		case <-time.After(interval):
+		}
+	}
+}
+
+func attemptProbe(ctx context.Context, client *engine.Client, containerID string, probe Probe) error {
+	ctx, cancel := context.WithTimeout(ctx, probeAttemptTimeout)
+	defer cancel()
+
+	switch probe.Kind {
+	case ProbeHTTP:
+		return attemptHTTP(ctx, client, containerID, probe)
+	case ProbeTCP:
+		address := probe.Address
+		if address == "" {
+			host, port, err := publishedAddress(ctx, client, containerID, probe)
+			if err != nil {
+				return err
+			}
+			address = net.JoinHostPort(host, port)
+		}
+		var dialer net.Dialer
+		connection, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return connection.Close()
+	case ProbeExec:
+		result, err := client.ContainerExec(ctx, containerID, probe.Command)
+		if err != nil {
+			return err
+		}
+		if result.ExitCode != 0 {
+			return fmt.Errorf("exited %d: %s", result.ExitCode, probeOutput(result.Output))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown probe kind %q", probe.Kind)
+	}
+}
+
+func attemptHTTP(ctx context.Context, client *engine.Client, containerID string, probe Probe) error {
+	target := probe.URL
+	if target == "" {
+		host, port, err := publishedAddress(ctx, client, containerID, probe)
+		if err != nil {
+			return err
+		}
+		scheme := probe.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		target = fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, port), probe.Path)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if probe.Status != 0 && response.StatusCode != probe.Status {
+		return fmt.Errorf("status %d, expected %d", response.StatusCode, probe.Status)
+	}
+	if probe.Status == 0 && (response.StatusCode < 200 || response.StatusCode >= 400) {
+		return fmt.Errorf("status %d", response.StatusCode)
+	}
+
+	if probe.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(response.Body, maxProbeBody))
+		if err != nil {
+			return err
+		}
+		if !probe.Body.Match(body) {
+			return fmt.Errorf("body does not match %q: %s", probe.Body, probeOutput(string(body)))
+		}
+	}
+	return nil
+}
+
+// publishedAddress returns the host and host port the container port of the probe is published on.
+func publishedAddress(ctx context.Context, client *engine.Client, containerID string, probe Probe) (string, string, error) {
+	host := probe.Host
+	if host == "" {
+		host = client.Hostname()
+	}
+
+	container, err := client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", "", err
+	}
+	for _, binding := range container.NetworkSettings.Ports[strconv.Itoa(probe.Port)+"/tcp"] {
+		if binding.HostPort != "" {
+			return host, binding.HostPort, nil
+		}
+	}
+	return "", "", fmt.Errorf("port %d is not published", probe.Port)
+}
@@ -0,0 +1,111 @@
+package validation
+
+import (
+	"docker-deployment/src/utils"
+	"fmt"
+	"time"
+)
+
+// Final states of a validated container.
+const (
+	StateHealthy   = "healthy"
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateUnhealthy = "unhealthy"
+	StateFailed    = "failed"
+	StateTimeout   = "timeout"
+	StateCancelled = "cancelled"
+	StateSkipped   = "skipped"
+)
+
+// ContainerResult is the outcome of validating a single container.
+type ContainerResult struct {
+	Name string
+	ID   string
+	// State is the final state of the validation, Status the last status reported by docker
+	State    string
+	Status   string
+	Err      error
+	Duration time.Duration
+	// Diagnosis explains the failure, it is nil when the container passed or was cancelled
+	Diagnosis *Diagnosis
+	// Probes are the results of the readiness probes of the container
+	Probes []ProbeResult
+}
+
+// Succeeded reports whether the container passed validation.
+func (r ContainerResult) Succeeded() bool {
+	return r.State == StateHealthy || r.State == StateRunning || r.State == StateCompleted
+}
+
+// Report is the outcome of validating every container of a deployment.
+type Report struct {
+	Containers []ContainerResult
+	Duration   time.Duration
+	// firstFailure is the container whose failure cancelled the others
+	firstFailure string
+}
+
+// Err returns the failure that stopped the validation, or nil when every container passed.
+func (r *Report) Err() error {
+	for _, result := range r.Containers {
+		if result.Name == r.firstFailure && result.Err != nil {
+			return result.Err
+		}
+	}
+	for _, result := range r.Containers {
+		if result.Err != nil {
+			return result.Err
+		}
+	}
+	return nil
+}
+
+// Print prints the final state of every container.
+func (r *Report) Print() {
+	color := utils.ColorGreen
+	if r.Err() != nil {
+		color = utils.ColorRed
+	}
+
+	utils.Logger(color, "Health validation finished in %s:", r.Duration.Round(time.Second))
+	for _, result := range r.Containers {
+		line := fmt.Sprintf("  %-30s %-10s %-10s %6s", result.Name, utils.GetShortId(result.ID), result.State,
+			result.Duration.Round(time.Second))
+		if result.Err != nil && result.State != StateCancelled && result.State != StateSkipped {
+			line += "  " + result.Err.Error()
+		}
+		if result.State == StateSkipped {
+			line += "  (dependency failed)"
+		}
+		if result.Diagnosis != nil {
+			line += " (" + result.Diagnosis.Summary() + ")"
+		}
+
+		switch {
+		case result.Succeeded():
+			utils.Logger(utils.ColorGreen, "%s", line)
+		case result.State == StateCancelled, result.State == StateSkipped:
+			utils.Logger(utils.ColorYellow, "%s", line)
+		default:
+			utils.Logger(utils.ColorRed, "%s", line)
+		}
+		printProbes(result.Probes)
+	}
+	for _, result := range r.Containers {
+		if result.Diagnosis != nil {
+			result.Diagnosis.Print(result.Name, result.ID)
+		}
+	}
+}
+
+func printProbes(probes []ProbeResult) {
+	for _, probe := range probes {
+		if probe.Passed {
+			utils.Logger(utils.ColorGreen, "    probe %s passed after %d attempts (%s)", probe.Probe, probe.Attempts,
+				probe.Duration.Round(time.Millisecond))
+		} else if probe.Err != nil {
+			utils.Logger(utils.ColorRed, "    probe %s failed after %d attempts: %s", probe.Probe, probe.Attempts, probe.Err)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package validation
+
+import (
+	"context"
+	"docker-deployment/src/engine"
+	"docker-deployment/src/utils"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSmokeTestTimeout bounds a smoke test, including its retries.
+	DefaultSmokeTestTimeout = 2 * time.Minute
+	// smokeTestLabel marks the containers created to run smoke tests.
+	smokeTestLabel = "docker-deployment.smoke-test"
+)
+
+// SmokeTest is a check run once every container of a deployment is healthy. Exactly one of HTTP and
+// Container is set.
+type SmokeTest struct {
+	Name      string
+	HTTP      *HTTPRequest
+	Container *TestContainer
+	Timeout   time.Duration
+	// Retries is the number of extra attempts of an HTTP test, Interval the pause between them
+	Retries  int
+	Interval time.Duration
+}
+
+// HTTPRequest is a request whose response must match the expected status, headers and body.
+type HTTPRequest struct {
+	Method string
+	// URL is requested as is; when empty, Service, Port and Path build it from the published port.
+	URL     string
+	Service string
+	Port    int
+	Path    string
+	Headers map[string]string
+	Body    string
+	// Status is the expected status, any 2xx when zero
+	Status          int
+	ResponseHeaders map[string]*regexp.Regexp
+	ResponseBody    *regexp.Regexp
+}
+
+// TestContainer is a one-off container attached to the project network that must exit 0.
+type TestContainer struct {
+	Image   string
+	Command []string
+	Env     []string
+	// Network defaults to the network of the deployed containers
+	Network string
+}
+
+// SmokeEnvironment is the deployment the smoke tests run against.
+type SmokeEnvironment struct {
+	// Containers maps each service to the id of its container
+	Containers map[string]string
+	Network    string
+	// LogLines is the number of log lines of a failed test container that are printed
+	LogLines int
+}
+
+// SmokeResult is the outcome of a smoke test.
+type SmokeResult struct {
+	Name     string
+	Kind     string
+	Passed   bool
+	Attempts int
+	Duration time.Duration
+	Err      error
+	// Logs are the last log lines of a test container
+	Logs []string
+}
+
+// RunSmokeTests runs the smoke tests in order, stopping at the first failure, and prints the result of each.
+func RunSmokeTests(ctx context.Context, tests []SmokeTest, environment SmokeEnvironment) ([]SmokeResult, error) {
+	if len(tests) == 0 {
+		return nil, nil
+	}
+
+	client, err := engine.Default()
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Logger(utils.ColorBlue, "Running %d smoke tests...", len(tests))
+
+	var results []SmokeResult
+	var failure error
+	for _, test := range tests {
+		if failure != nil {
+			results = append(results, SmokeResult{Name: test.Name, Kind: test.kind()})
+			continue
+		}
+
+		result := runSmokeTest(ctx, client, test, environment)
+		results = append(results, result)
+		if !result.Passed {
+			failure = fmt.Errorf("smoke test %q failed: %w", test.Name, result.Err)
+		}
+	}
+
+	printSmokeResults(results)
+	return results, failure
+}
+
+func (t SmokeTest) kind() string {
+	if t.Container != nil {
+		return "container"
+	}
+	return "http"
+}
+
+func runSmokeTest(ctx context.Context, client *engine.Client, test SmokeTest, environment SmokeEnvironment) SmokeResult {
+	timeout := test.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSmokeTestTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	result := SmokeResult{Name: test.Name, Kind: test.kind()}
+
+	if test.Container != nil {
+		result.Attempts = 1
+		result.Logs, result.Err = runTestContainer(ctx, client, test, environment)
+	} else {
+		interval := test.Interval
+		if interval <= 0 {
+			interval = DefaultProbeInterval
+		}
+		for attempt := 0; attempt <= test.Retries; attempt++ {
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(interval):
+				}
+				if ctx.Err() != nil {
+					break
+				}
+			}
+			result.Attempts++
+			if result.Err = runHTTPTest(ctx, client, test.HTTP, environment); result.Err == nil {
+				break
+			}
+		}
+	}
+
+	result.Passed = result.Err == nil
+	result.Duration = time.Since(started)
+	return result
+}
+
+func runHTTPTest(ctx context.Context, client *engine.Client, test *HTTPRequest, environment SmokeEnvironment) error {
+	target := test.URL
+	if target == "" {
+		containerID, ok := environment.Containers[test.Service]
+		if !ok {
+			return fmt.Errorf("service %s has no container", test.Service)
+		}
+		host, port, err := publishedAddress(ctx, client, containerID, Probe{Port: test.Port})
+		if err != nil {
+			return err
+		}
+		target = fmt.Sprintf("http://%s%s", net.JoinHostPort(host, port), test.Path)
+	}
+
+	method := test.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if test.Body != "" {
+		body = strings.NewReader(test.Body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	for key, value := range test.Headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if test.Status != 0 && response.StatusCode != test.Status {
+		return fmt.Errorf("%s %s: status %d, expected %d", method, target, response.StatusCode, test.Status)
+	}
+	if test.Status == 0 && (response.StatusCode < 200 || response.StatusCode >= 300) {
+		return fmt.Errorf("%s %s: status %d", method, target, response.StatusCode)
+	}
+
+	for header, expected := range test.ResponseHeaders {
+		if value := response.Header.Get(header); !expected.MatchString(value) {
+			return fmt.Errorf("%s %s: header %s %q does not match %q", method, target, header, value, expected)
+		}
+	}
+
+	if test.ResponseBody != nil {
+		content, err := io.ReadAll(io.LimitReader(response.Body, maxProbeBody))
+		if err != nil {
+			return err
+		}
+		if !test.ResponseBody.Match(content) {
+			return fmt.Errorf("%s %s: body does not match %q: %s", method, target, test.ResponseBody, probeOutput(string(content)))
+		}
+	}
+	return nil
+}
+
+// runTestContainer runs the test container to completion and returns its last log lines.
+func runTestContainer(ctx context.Context, client *engine.Client, test SmokeTest, environment SmokeEnvironment) ([]string, error) {
+	container := test.Container
+
+	if _, err := client.ImageInspect(ctx, container.Image); errors.Is(err, engine.ErrNotFound) {
+		utils.Logger(utils.ColorBlue, "Pulling smoke test image %s...", container.Image)
+		if err := client.ImagePull(ctx, container.Image, nil); err != nil {
+			return nil, fmt.Errorf("error pulling %s: %w", container.Image, err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	network := container.Network
+	if network == "" {
+		network = environment.Network
+	}
+
+	containerID, err := client.ContainerCreate(ctx, engine.CreateOptions{
+		Name:        "smoke-test-" + uuid.New().String()[:8],
+		Image:       container.Image,
+		Cmd:         container.Command,
+		Env:         container.Env,
+		Labels:      map[string]string{smokeTestLabel: test.Name},
+		NetworkMode: network,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating test container: %w", err)
+	}
+	defer func() {
+		_ = client.ContainerRemove(context.Background(), containerID, true)
+	}()
+
+	if err := client.ContainerStart(ctx, containerID); err != nil {
+		return nil, fmt.Errorf("error starting test container: %w", err)
+	}
+
+	exitCode, err := client.ContainerWait(ctx, containerID)
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("test container did not finish in time")
+	}
+
+	logLines := environment.LogLines
+	if logLines <= 0 {
+		logLines = DefaultLogLines
+	}
+	logs, _ := client.ContainerLogLines(context.Background(), containerID, logLines)
+
+	if err != nil {
+		return logs, err
+	}
+	if exitCode != 0 {
+		return logs, fmt.Errorf("test container exited %d", exitCode)
+	}
+	return logs, nil
+}
+
+func printSmokeResults(results []SmokeResult) {
+	utils.Logger(utils.ColorBlue, "Smoke tests:")
+	for _, result := range results {
+		switch {
+		case result.Passed:
+			utils.Logger(utils.ColorGreen, "  %-40s %-9s passed  %s", result.Name, result.Kind, result.Duration.Round(time.Millisecond))
+		case result.Attempts == 0:
+			utils.Logger(utils.ColorYellow, "  %-40s %-9s skipped", result.Name, result.Kind)
+		default:
+			utils.Logger(utils.ColorRed, "  %-40s %-9s failed  %s after %d attempts: %s", result.Name, result.Kind,
+				result.Duration.Round(time.Millisecond), result.Attempts, result.Err)
+		}
+	}
+
+	for _, result := range results {
+		if result.Passed || len(result.Logs) == 0 {
+			continue
+		}
+		utils.Logger(utils.ColorRed, "Last log lines of smoke test %q:", result.Name)
+		for _, line := range result.Logs {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+}
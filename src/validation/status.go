@@ -0,0 +1,87 @@
+package validation
+
+import (
+	"context"
+	"docker-deployment/src/utils"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statusInterval is how often the aggregate status line is printed when nothing changes.
+const statusInterval = 30 * time.Second
+
+// statusBoard keeps the current status of every validated container and prints an aggregate line.
+type statusBoard struct {
+	mutex    sync.Mutex
+	statuses map[string]string
+	deadline time.Time
+	last     string
+}
+
+func newStatusBoard(names []string, deadline time.Time) *statusBoard {
+	board := &statusBoard{statuses: map[string]string{}, deadline: deadline}
+	for _, name := range names {
+		board.statuses[name] = "pending"
+	}
+	return board
+}
+
+// update sets the status of a container, printing the aggregate line when it changed.
+func (b *statusBoard) update(name string, status string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.statuses[name] = status
+	if summary := b.summary(); summary != b.last {
+		b.last = summary
+		b.print(summary)
+	}
+}
+
+// run prints the aggregate line periodically until ctx is done.
+func (b *statusBoard) run(ctx context.Context) {
+	ticker := time.NewTicker(statusInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.mutex.Lock()
+			b.print(b.summary())
+			b.mutex.Unlock()
+		}
+	}
+}
+
+// summary counts the containers in each status, e.g. "2 healthy, 1 starting".
+func (b *statusBoard) summary() string {
+	counts := map[string]int{}
+	for _, status := range b.statuses {
+		counts[status]++
+	}
+
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	parts := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (b *statusBoard) print(summary string) {
+	remaining := time.Until(b.deadline).Round(time.Second)
+	if remaining < 0 {
+		remaining = 0
+	}
+	utils.Logger(utils.ColorBlue, "Health of %d containers: %s (%s left)", len(b.statuses), summary, remaining)
+}